@@ -4,5 +4,7 @@ go 1.16
 
 require (
 	github.com/cloudflare/cloudflare-go v0.55.0
+	github.com/miekg/dns v1.1.50
+	golang.org/x/net v0.1.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/YoRyan/dsddns/updater"
@@ -16,8 +19,10 @@ import (
 )
 
 const (
-	progName  = "dsddns"
-	sleepTime = 5 * time.Minute
+	progName   = "dsddns"
+	sleepTime  = 5 * time.Minute
+	settleTime = 2 * time.Second
+	drainTime  = 10 * time.Second
 )
 
 type mode int
@@ -41,6 +46,8 @@ func main() {
 	flag.BoolVar(&opDryRun, "dryrun", false, "read the configuration file, but do not push any updates")
 	var opRunOnce bool
 	flag.BoolVar(&opRunOnce, "oneshot", false, "run a single update")
+	var metricsListen string
+	flag.StringVar(&metricsListen, "metrics-listen", "", "serve Prometheus metrics at this address, such as :9100")
 	var showVersion bool
 	flag.BoolVar(&showVersion, "version", false, "show the version number")
 	flag.Parse()
@@ -59,7 +66,17 @@ func main() {
 		op = runRepeating
 	}
 	logger := log.New(os.Stdout, progName+": ", log.LstdFlags)
-	if err := run(context.Background(), logger, op); err != nil {
+	if metricsListen != "" {
+		go serveMetrics(logger, metricsListen)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Let a second signal kill the program if shutting down takes too long.
+		<-ctx.Done()
+		stop()
+	}()
+	if err := run(ctx, logger, op); err != nil {
 		logger.Fatalln(err)
 		os.Exit(2)
 	}
@@ -71,42 +88,178 @@ func run(ctx context.Context, logger *log.Logger, op mode) error {
 		return errors.New("missing path to a configuration file")
 	}
 
-	file, err := os.Open(path)
+	updaters, err := readConfig(path, op != dryRun)
 	if err != nil {
 		return err
 	}
 
-	updaters, err := loadConfig(file)
-	if err != nil {
-		return err
-	}
+	// Let an update in progress finish before shutting down, but not forever.
+	work, cancel := drainContext(ctx, drainTime)
+	defer cancel()
 
 	if op == dryRun {
-		updaters.DryRun(ctx, logger)
+		updaters.DryRun(work, logger)
 	} else if op == runOnce {
-		updaters.Update(ctx, logger)
+		updaters.Update(work, logger)
 	} else if op == runRepeating {
-		for {
-			updaters.Update(ctx, logger)
-			time.Sleep(sleepTime)
-		}
+		repeat(ctx, work, logger, path, updaters)
 	}
 	return nil
 }
 
-func loadConfig(r io.Reader) (updater.Updaters, error) {
-	data, err := io.ReadAll(r)
+// drainContext returns a context that is canceled some time after the parent
+// context is done.
+func drainContext(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-parent.Done():
+		case <-ctx.Done():
+			return
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// repeat updates all records every few minutes until the context is done. On
+// platforms that support it, records bound to an interface are also updated as
+// soon as that interface's addresses change, and records that read the address
+// from the router are updated when the router announces a change. Updates are
+// performed with the work context.
+func repeat(ctx context.Context, work context.Context, logger *log.Logger, path string, updaters updater.Updaters) {
+	changes, err := updater.WatchAddresses(ctx, logger)
+	if err != nil {
+		logger.Println("not watching for address changes:", err)
+	}
+	var announcements <-chan struct{}
+	watchRouter := func() {
+		if announcements != nil || !updaters.UsesRouter() {
+			return
+		}
+		var err error
+		if announcements, err = updater.WatchRouter(ctx, logger); err != nil {
+			logger.Println("not watching for router announcements:", err)
+		}
+	}
+	watchRouter()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	updaters.Update(work, logger)
+	ticker := time.NewTicker(sleepTime)
+	defer ticker.Stop()
+
+	// Address changes tend to arrive in bursts, so wait for them to settle.
+	pending := make(map[string]bool)
+	var announced bool
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Println("shutting down")
+			return
+		case <-hup:
+			next, err := readConfig(path, true)
+			if err != nil {
+				logger.Println("failed to reload configuration:", err)
+				continue
+			}
+			next.Inherit(updaters)
+			updaters = next
+			logger.Println("reloaded configuration")
+			watchRouter()
+			updaters.Update(work, logger)
+		case <-ticker.C:
+			updaters.Update(work, logger)
+		case intname, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			pending[intname] = true
+			if settle == nil {
+				settle = time.After(settleTime)
+			}
+		case _, ok := <-announcements:
+			if !ok {
+				announcements = nil
+				continue
+			}
+			announced = true
+			if settle == nil {
+				settle = time.After(settleTime)
+			}
+		case <-settle:
+			for intname := range pending {
+				updaters.UpdateInterface(work, logger, intname)
+				delete(pending, intname)
+			}
+			if announced {
+				updaters.UpdateRouter(work, logger)
+				announced = false
+			}
+			settle = nil
+		}
+	}
+}
+
+func serveMetrics(logger *log.Logger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", updater.MetricsHandler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Println("metrics listener failed:", err)
+	}
+}
+
+type config struct {
+	Records    updater.Updaters
+	StateFile  string              `yaml:"state_file"`
+	IPServices *updater.IPServices `yaml:"ip_services"`
+}
+
+// readConfig reads the records from a configuration file. If useState is set,
+// their state is restored from the state file, if there is one.
+func readConfig(path string, useState bool) (updater.Updaters, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	conf, err := loadConfig(file)
 	if err != nil {
 		return nil, err
 	}
+	if conf.StateFile != "" && useState {
+		if err := conf.Records.LoadState(conf.StateFile); err != nil {
+			return nil, err
+		}
+	}
+	return conf.Records, nil
+}
 
-	var config struct {
-		Records updater.Updaters
+func loadConfig(r io.Reader) (*config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
-	err = yaml.Unmarshal(data, &config)
+
+	var conf config
+	err = yaml.Unmarshal(data, &conf)
 	if err != nil {
 		return nil, err
 	}
+	if conf.IPServices != nil {
+		conf.Records.UseIPServices(conf.IPServices)
+	}
 
-	return config.Records, nil
+	return &conf, nil
 }
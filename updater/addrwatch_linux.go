@@ -0,0 +1,100 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Not defined by the syscall package.
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// WatchAddresses subscribes to the kernel's address notifications and reports
+// the name of each network interface that gains or loses an address. If
+// notifications are lost, every interface is reported. The channel is closed
+// when the context is done, or if the subscription fails.
+func WatchAddresses(ctx context.Context, logger *log.Logger) (<-chan string, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// Use the runtime poller so that closing the file interrupts a pending read.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "netlink")
+
+	changes := make(chan string)
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	send := func(intname string) bool {
+		select {
+		case changes <- intname:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(changes)
+		buf := make([]byte, os.Getpagesize())
+		for {
+			n, err := file.Read(buf)
+			if errors.Is(err, syscall.ENOBUFS) {
+				// A burst of notifications overflowed the socket buffer, so
+				// any interface may have changed.
+				intfs, _ := net.Interfaces()
+				for _, intf := range intfs {
+					if !send(intf.Name) {
+						return
+					}
+				}
+				continue
+			} else if err != nil {
+				if ctx.Err() == nil {
+					logger.Println("stopped watching for address changes:", err)
+				}
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				if m.Header.Type != syscall.RTM_NEWADDR && m.Header.Type != syscall.RTM_DELADDR {
+					continue
+				}
+				if len(m.Data) < syscall.SizeofIfAddrmsg {
+					continue
+				}
+				ifam := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+				intf, err := net.InterfaceByIndex(int(ifam.Index))
+				if err != nil {
+					continue
+				}
+				if !send(intf.Name) {
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
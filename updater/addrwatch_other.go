@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package updater
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// WatchAddresses subscribes to address change notifications. It is only
+// supported on Linux.
+func WatchAddresses(ctx context.Context, logger *log.Logger) (<-chan string, error) {
+	return nil, errors.New("address notifications are not supported on this platform")
+}
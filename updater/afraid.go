@@ -0,0 +1,177 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FreeDNS (afraid.org) dynamic DNS protocols
+// see https://freedns.afraid.org/dynamic/v2/ and https://freedns.afraid.org/api/
+
+const (
+	afraidCooldown      = 15 * time.Minute
+	afraidForever       = 10 * time.Hour * 24 * 365
+	afraidSyncEndpoint  = "https://sync.afraid.org/u/"
+	afraidSync6Endpoint = "https://v6.sync.afraid.org/u/"
+	afraidAPIEndpoint   = "https://freedns.afraid.org/api/"
+)
+
+// AfraidService implements the FreeDNS dynamic DNS protocols. With a token, it
+// uses the version 2 update URLs. With a username and password, it uses the
+// version 1 API to look up the update URL for the hostname.
+type AfraidService struct {
+	conf          *afraidServiceConf
+	syncEndpoint  string
+	sync6Endpoint string
+	apiEndpoint   string
+	updateURL     string
+}
+
+type afraidServiceConf struct {
+	Token    string
+	Username string
+	Password string
+	Hostname string
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *AfraidService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	var requrl string
+	if s.conf.Token != "" {
+		endpoint := s.syncEndpoint
+		if rtype == AAAARecord {
+			endpoint = s.sync6Endpoint
+		}
+		qs := url.Values{}
+		qs.Add("address", ip.String())
+		requrl = endpoint + url.PathEscape(s.conf.Token) + "/?" + qs.Encode()
+	} else {
+		if s.updateURL == "" {
+			if retryAfter, err = s.findUpdateURL(ctx); err != nil {
+				return
+			}
+		}
+		requrl = s.updateURL + "&address=" + url.QueryEscape(ip.String())
+	}
+
+	body, err := afraidGet(ctx, requrl)
+	if err != nil {
+		retryAfter = afraidCooldown
+		return
+	}
+	switch {
+	case strings.Contains(body, "has not changed"), strings.Contains(body, "No IP change"):
+		return
+	case strings.HasPrefix(body, "ERROR"):
+		// The update URL may have been regenerated.
+		s.updateURL = ""
+		retryAfter = afraidCooldown
+		err = errors.New(body)
+	case strings.Contains(body, "Updated"):
+		return
+	default:
+		retryAfter = afraidCooldown
+		err = errors.New("unexpected response: " + body)
+	}
+	return
+}
+
+// findUpdateURL looks up the update URL for the hostname with the version 1
+// API.
+func (s *AfraidService) findUpdateURL(ctx context.Context) (retryAfter time.Duration, err error) {
+	hash := sha1.Sum([]byte(strings.ToLower(s.conf.Username) + "|" + s.conf.Password))
+	qs := url.Values{}
+	qs.Add("action", "getdyndns")
+	qs.Add("v", "2")
+	qs.Add("sha", hex.EncodeToString(hash[:]))
+
+	body, err := afraidGet(ctx, s.apiEndpoint+"?"+qs.Encode())
+	if err != nil {
+		retryAfter = afraidCooldown
+		return
+	}
+	if strings.HasPrefix(body, "ERROR") {
+		retryAfter = afraidForever
+		err = errors.New(body + " Will not attempt further updates.")
+		return
+	}
+	// Each line lists a hostname, its current address, and its update URL.
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) == 3 && strings.EqualFold(fields[0], s.conf.Hostname) {
+			s.updateURL = fields[2]
+			return
+		}
+	}
+	retryAfter = afraidCooldown
+	err = errors.New("no dynamic DNS host named " + s.conf.Hostname)
+	return
+}
+
+func afraidGet(ctx context.Context, requrl string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", requrl, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("bad response code")
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+// Records without a hostname are told apart by a digest of their tokens.
+func (s *AfraidService) Identifier() string {
+	if s.conf.Hostname == "" {
+		sum := sha1.Sum([]byte(s.conf.Token))
+		return "FreeDNS token " + hex.EncodeToString(sum[:4])
+	}
+	return s.conf.Hostname
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *AfraidService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *AfraidService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &afraidServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.Token == "" && (s.conf.Username == "" || s.conf.Hostname == "") {
+		return errors.New("missing token, or username and hostname")
+	}
+	s.syncEndpoint = afraidSyncEndpoint
+	s.sync6Endpoint = afraidSync6Endpoint
+	s.apiEndpoint = afraidAPIEndpoint
+	return nil
+}
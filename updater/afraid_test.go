@@ -0,0 +1,90 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAfraidSubmit(t *testing.T) {
+	hash := sha1.Sum([]byte("user|pass"))
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		switch r.URL.Path {
+		case "/api/":
+			if qs.Get("sha") != hex.EncodeToString(hash[:]) {
+				io.WriteString(w, "ERROR: Could not authenticate.")
+				return
+			}
+			io.WriteString(w, "other.mooo.com|192.0.2.9|"+server.URL+"/dynamic/update.php?other\n")
+			io.WriteString(w, "host.mooo.com|192.0.2.1|"+server.URL+"/dynamic/update.php?secret\n")
+		case "/dynamic/update.php":
+			if qs.Get("address") == "192.0.2.1" {
+				io.WriteString(w, "ERROR: Address 192.0.2.1 has not changed.")
+			} else if _, ok := qs["secret"]; ok {
+				io.WriteString(w, "Updated 1 host(s) host.mooo.com to "+qs.Get("address")+" in 0.1 seconds")
+			} else {
+				io.WriteString(w, "ERROR: Unable to locate this record")
+			}
+		case "/u/token/":
+			io.WriteString(w, "No IP change detected for host.mooo.com with IP "+qs.Get("address")+", skipping update")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	v1 := &AfraidService{
+		conf:        &afraidServiceConf{Username: "User", Password: "pass", Hostname: "host.mooo.com"},
+		apiEndpoint: server.URL + "/api/",
+	}
+	for _, ip := range []string{"192.0.2.2", "192.0.2.1"} {
+		if _, err := v1.Submit(context.Background(), ARecord, net.ParseIP(ip)); err != nil {
+			t.Errorf("Submit %s: %v", ip, err)
+		}
+	}
+
+	v1.conf.Password = "wrong"
+	v1.updateURL = ""
+	if retryAfter, err := v1.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2")); err == nil || retryAfter != afraidForever {
+		t.Errorf("Submit = %s, %v; want a fatal error", retryAfter, err)
+	}
+
+	v2 := &AfraidService{
+		conf:          &afraidServiceConf{Token: "token"},
+		syncEndpoint:  server.URL + "/u/",
+		sync6Endpoint: server.URL + "/u/",
+	}
+	if _, err := v2.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAfraidIdentifier(t *testing.T) {
+	var first, second Updater
+	if err := yaml.Unmarshal([]byte("service: afraid\ntype: A\ntoken: first"), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte("service: afraid\ntype: A\ntoken: second"), &second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Service.Identifier() == second.Service.Identifier() {
+		t.Errorf("Records with different tokens share the identifier %q", first.Service.Identifier())
+	}
+
+	var u Updater
+	if err := yaml.Unmarshal([]byte("service: afraid\ntype: A\ntoken: first\nverify: true"), &u); err == nil {
+		t.Error("Verifying a record without a hostname should be an error")
+	}
+	if err := yaml.Unmarshal([]byte("service: afraid\ntype: A\ntoken: first\nhostname: host.mooo.com\nverify: true"), &u); err != nil {
+		t.Error(err)
+	}
+}
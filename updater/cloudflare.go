@@ -14,10 +14,14 @@ const (
 	cloudflareCooldown = 15 * time.Minute
 )
 
-// CloudflareService implements the Cloudflare DNS protocol.
+// CloudflareService implements the Cloudflare DNS protocol. If the zone and
+// record identifiers are not configured, they are looked up by name the first
+// time they are needed, and again if Cloudflare no longer recognizes them.
 type CloudflareService struct {
-	conf *cloudflareServiceConf
-	api  *cloudflare.API
+	conf     *cloudflareServiceConf
+	api      *cloudflare.API
+	zoneID   string
+	recordID string
 }
 
 type cloudflareServiceConf struct {
@@ -28,6 +32,8 @@ type cloudflareServiceConf struct {
 	RecordID string `yaml:"record_id"`
 	Name     string
 	TTL      int
+
+	CreateIfMissing bool `yaml:"create_if_missing"`
 }
 
 // Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
@@ -44,14 +50,66 @@ func (s *CloudflareService) Submit(ctx context.Context, rtype RecordType, ip net
 		Content: ip.String(),
 		TTL:     ttl,
 	}
-	err = s.api.UpdateDNSRecord(ctx, s.conf.ZoneID, s.conf.RecordID, record)
-	var cfErr cloudflare.Error
-	if errors.As(err, &cfErr) {
+	err = s.findRecord(ctx, rtype)
+	var missing missingRecordError
+	if errors.As(err, &missing) && s.conf.CreateIfMissing {
+		var resp *cloudflare.DNSRecordResponse
+		resp, err = s.api.CreateDNSRecord(ctx, s.zoneID, record)
+		if err == nil {
+			s.recordID = resp.Result.ID
+		}
+	} else if err == nil {
+		err = s.api.UpdateDNSRecord(ctx, s.zoneID, s.recordID, record)
+	}
+
+	// Forget identifiers that were looked up, in case the record was deleted
+	// and recreated.
+	var notFound *cloudflare.NotFoundError
+	if errors.As(err, &notFound) {
+		s.zoneID, s.recordID = s.conf.ZoneID, s.conf.RecordID
+	}
+	var cfErr interface{ ErrorCodes() []int }
+	var missingZone missingZoneError
+	if errors.As(err, &cfErr) || errors.As(err, &missing) || errors.As(err, &missingZone) {
 		retryAfter = cloudflareCooldown
 	}
 	return
 }
 
+// findRecord looks up the zone and record identifiers, if necessary.
+func (s *CloudflareService) findRecord(ctx context.Context, rtype RecordType) error {
+	if s.zoneID == "" {
+		for _, candidate := range zoneCandidates(s.conf.Name) {
+			zones, err := s.api.ListZones(ctx, candidate)
+			if err != nil {
+				return err
+			}
+			if len(zones) > 0 {
+				s.zoneID = zones[0].ID
+				break
+			}
+		}
+		if s.zoneID == "" {
+			return missingZoneError{s.conf.Name}
+		}
+	}
+
+	if s.recordID == "" {
+		records, err := s.api.DNSRecords(ctx, s.zoneID, cloudflare.DNSRecord{
+			Type: RecordTypeString(rtype),
+			Name: s.conf.Name,
+		})
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return missingRecordError{s.conf.Name, rtype}
+		}
+		s.recordID = records[0].ID
+	}
+	return nil
+}
+
 // Identifier returns a human readable name for this service given its endpoint.
 func (s *CloudflareService) Identifier() string {
 	return s.conf.Name
@@ -76,6 +134,7 @@ func (s *CloudflareService) UnmarshalYAML(value *yaml.Node) error {
 	if err != nil {
 		return err
 	}
+	s.zoneID, s.recordID = s.conf.ZoneID, s.conf.RecordID
 	if s.conf.APIKey != "" && s.conf.APIEmail != "" {
 		s.api, err = cloudflare.New(s.conf.APIKey, s.conf.APIEmail)
 		if err != nil {
@@ -86,6 +145,8 @@ func (s *CloudflareService) UnmarshalYAML(value *yaml.Node) error {
 		if err != nil {
 			return err
 		}
+	} else {
+		return errors.New("missing api_token, or api_key and api_email")
 	}
 	return nil
 }
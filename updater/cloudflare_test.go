@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCloudflareCreateIfMissing(t *testing.T) {
+	var created, updated int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/zones", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "example.com" {
+			io.WriteString(w, `{"success":true,"result":[{"id":"zone1","name":"example.com"}]}`)
+		} else {
+			io.WriteString(w, `{"success":true,"result":[]}`)
+		}
+	})
+	mux.HandleFunc("/zones/zone1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			io.WriteString(w, `{"success":true,"result":[],"result_info":{"page":1,"total_pages":1}}`)
+		case http.MethodPost:
+			var record cloudflare.DNSRecord
+			json.NewDecoder(r.Body).Decode(&record)
+			if record.Name != "host.example.com" || record.Type != "AAAA" || record.Content != "2001:db8::1" || record.TTL != 120 {
+				t.Errorf("Created record = %+v; want host.example.com AAAA 2001:db8::1 with TTL 120", record)
+			}
+			created++
+			io.WriteString(w, `{"success":true,"result":{"id":"record1"}}`)
+		}
+	})
+	mux.HandleFunc("/zones/zone1/dns_records/record1", func(w http.ResponseWriter, r *http.Request) {
+		updated++
+		io.WriteString(w, `{"success":true,"result":{"id":"record1"}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CloudflareService{
+		conf: &cloudflareServiceConf{Name: "host.example.com", TTL: 120},
+		api:  api,
+	}
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("Submit should fail when the record is missing and create_if_missing is not set")
+	}
+
+	s.conf.CreateIfMissing = true
+	for i := 0; i < 2; i++ {
+		if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if created != 1 || updated != 1 {
+		t.Errorf("Records created = %d, updated = %d; want 1 and 1", created, updated)
+	}
+}
+
+func TestCloudflareLookupAtStartup(t *testing.T) {
+	var lookups int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		io.WriteString(w, `{"success":true,"result":[]}`)
+	}))
+	defer server.Close()
+
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &Updater{
+		Type:      AAAARecord,
+		Source:    InterfaceSource,
+		Interface: "missing0",
+		Service: &CloudflareService{
+			conf: &cloudflareServiceConf{Name: "host.example.com"},
+			api:  api,
+		},
+	}
+	var logged bytes.Buffer
+	u.Update(context.Background(), log.New(&logged, "", 0))
+	if lookups == 0 || !strings.Contains(logged.String(), "cannot find a zone") {
+		t.Errorf("Lookups = %d, logged %q; want a failed zone lookup", lookups, logged.String())
+	}
+}
+
+func TestCloudflareRequiresCredentials(t *testing.T) {
+	var s CloudflareService
+	if err := yaml.Unmarshal([]byte("name: host.example.com"), &s); err == nil {
+		t.Error("A configuration without credentials should be an error")
+	}
+}
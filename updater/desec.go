@@ -0,0 +1,143 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deSEC API
+// see https://desec.readthedocs.io/en/latest/dns/rrsets.html
+
+const (
+	deSECCooldown   = 15 * time.Minute
+	deSECDefaultTTL = 3600
+	deSECEndpoint   = "https://desec.io/api/v1"
+)
+
+// DeSECService implements the deSEC API. If the domain is not configured, it
+// is looked up by name the first time it is needed.
+//
+// deSEC replaces all of the values of a record set at once, so records that
+// share a name and type are published together.
+type DeSECService struct {
+	conf     *deSECServiceConf
+	endpoint string
+	domain   string
+	set      *rrset
+}
+
+type deSECServiceConf struct {
+	Token  string
+	Domain string
+	Name   string
+	TTL    int
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *DeSECService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	if s.domain == "" {
+		if err = s.findDomain(ctx); err != nil {
+			retryAfter = s.retryAfter(err)
+			return
+		}
+	}
+
+	path := "/domains/" + url.PathEscape(s.domain) +
+		"/rrsets/" + url.PathEscape(relativeName(s.conf.Name, s.domain)) +
+		"/" + RecordTypeString(rtype) + "/"
+	body := struct {
+		Records []string `json:"records"`
+		TTL     int      `json:"ttl"`
+	}{
+		Records: s.set.values(s, ip),
+		TTL:     s.conf.TTL,
+	}
+	err = s.do(ctx, "PATCH", path, body, nil)
+
+	// Forget the domain if it was looked up, in case it moved.
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		s.domain = s.conf.Domain
+	}
+	if err != nil {
+		retryAfter = s.retryAfter(err)
+	}
+	return
+}
+
+// findDomain looks up the deSEC domain that contains the record.
+func (s *DeSECService) findDomain(ctx context.Context) error {
+	qs := url.Values{}
+	qs.Add("owns_qname", s.conf.Name)
+	var domains []struct {
+		Name string
+	}
+	if err := s.do(ctx, "GET", "/domains/?"+qs.Encode(), nil, &domains); err != nil {
+		return err
+	}
+	if len(domains) == 0 {
+		return missingZoneError{s.conf.Name}
+	}
+	s.domain = domains[0].Name
+	return nil
+}
+
+func (s *DeSECService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Token "+s.conf.Token)
+	return doJSON(ctx, method, s.endpoint+path, header, in, out)
+}
+
+// retryAfter determines when to try again after an error. deSEC throttles
+// requests strictly, and says when to try again.
+func (s *DeSECService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, deSECCooldown, func(header http.Header) time.Duration {
+		return parseRetryAfter(header.Get("Retry-After"))
+	})
+}
+
+func (s *DeSECService) rrsetKey() string {
+	return "desec " + strings.ToLower(s.conf.Name)
+}
+
+func (s *DeSECService) joinRRSet(set *rrset) {
+	s.set = set
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *DeSECService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *DeSECService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *DeSECService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &deSECServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = deSECDefaultTTL
+	}
+	s.endpoint = deSECEndpoint
+	s.domain = s.conf.Domain
+	return nil
+}
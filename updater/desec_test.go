@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeSECThrottle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/domains/" && r.URL.Query().Get("owns_qname") == "host.example.dedyn.io":
+			io.WriteString(w, `[{"name":"example.dedyn.io"}]`)
+		case r.URL.Path == "/domains/example.dedyn.io/rrsets/host/AAAA/" && r.Method == "PATCH":
+			w.Header().Set("Retry-After", "42")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"detail":"Request was throttled. Expected available in 42 seconds."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := &DeSECService{
+		conf:     &deSECServiceConf{Token: "token", Name: "host.example.dedyn.io", TTL: 3600},
+		endpoint: server.URL,
+	}
+	retryAfter, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1"))
+	if err == nil {
+		t.Fatal("Submit should fail when throttled")
+	}
+	if retryAfter != 42*time.Second {
+		t.Errorf("Retry delay = %s; want 42s", retryAfter)
+	}
+	if s.domain != "example.dedyn.io" {
+		t.Errorf("Domain = %q; want example.dedyn.io", s.domain)
+	}
+}
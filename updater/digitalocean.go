@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DigitalOcean API v2
+// see https://docs.digitalocean.com/reference/api/api-reference/#tag/Domain-Records
+
+const (
+	digitalOceanCooldown   = 15 * time.Minute
+	digitalOceanDefaultTTL = 1800
+	digitalOceanEndpoint   = "https://api.digitalocean.com/v2"
+)
+
+// DigitalOceanService implements the DigitalOcean DNS API. The domain and
+// record identifier are looked up by name the first time they are needed.
+type DigitalOceanService struct {
+	conf     *digitalOceanServiceConf
+	endpoint string
+	domain   string
+	recordID int
+}
+
+type digitalOceanServiceConf struct {
+	Token  string
+	Domain string
+	Name   string
+	TTL    int
+
+	CreateIfMissing bool `yaml:"create_if_missing"`
+}
+
+type digitalOceanRecord struct {
+	ID   int    `json:"id,omitempty"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+	TTL  int    `json:"ttl"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *DigitalOceanService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	return submitRecord(ctx, s, rtype, ip, s.conf.CreateIfMissing)
+}
+
+// findRecord looks up the domain and record identifier, if necessary.
+func (s *DigitalOceanService) findRecord(ctx context.Context, rtype RecordType) error {
+	if s.domain == "" {
+		for _, candidate := range zoneCandidates(s.conf.Name) {
+			err := s.do(ctx, "GET", "/domains/"+url.PathEscape(candidate), nil, nil)
+			var httpErr *httpError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			s.domain = candidate
+			break
+		}
+		if s.domain == "" {
+			return missingZoneError{s.conf.Name}
+		}
+	}
+
+	if s.recordID == 0 {
+		qs := url.Values{}
+		qs.Add("type", RecordTypeString(rtype))
+		qs.Add("name", s.conf.Name)
+		var resp struct {
+			DomainRecords []digitalOceanRecord `json:"domain_records"`
+		}
+		err := s.do(ctx, "GET", "/domains/"+url.PathEscape(s.domain)+"/records?"+qs.Encode(), nil, &resp)
+		if err != nil {
+			return err
+		}
+		if len(resp.DomainRecords) == 0 {
+			return missingRecordError{s.conf.Name, rtype}
+		}
+		s.recordID = resp.DomainRecords[0].ID
+	}
+	return nil
+}
+
+func (s *DigitalOceanService) createRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	var resp struct {
+		DomainRecord digitalOceanRecord `json:"domain_record"`
+	}
+	err := s.do(ctx, "POST", "/domains/"+url.PathEscape(s.domain)+"/records", s.record(rtype, ip), &resp)
+	if err == nil {
+		s.recordID = resp.DomainRecord.ID
+	}
+	return err
+}
+
+func (s *DigitalOceanService) updateRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	path := "/domains/" + url.PathEscape(s.domain) + "/records/" + strconv.Itoa(s.recordID)
+	return s.do(ctx, "PUT", path, s.record(rtype, ip), nil)
+}
+
+func (s *DigitalOceanService) record(rtype RecordType, ip net.IP) digitalOceanRecord {
+	return digitalOceanRecord{
+		Type: RecordTypeString(rtype),
+		Name: relativeName(s.conf.Name, s.domain),
+		Data: ip.String(),
+		TTL:  s.conf.TTL,
+	}
+}
+
+func (s *DigitalOceanService) forgetRecord() {
+	s.domain = s.conf.Domain
+	s.recordID = 0
+}
+
+func (s *DigitalOceanService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.conf.Token)
+	return doJSON(ctx, method, s.endpoint+path, header, in, out)
+}
+
+// retryAfter determines when to try again after an error. DigitalOcean reports
+// when its rate limit resets as a Unix time.
+func (s *DigitalOceanService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, digitalOceanCooldown, func(header http.Header) time.Duration {
+		reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return time.Until(time.Unix(reset, 0))
+	})
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *DigitalOceanService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *DigitalOceanService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *DigitalOceanService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &digitalOceanServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = digitalOceanDefaultTTL
+	}
+	s.endpoint = digitalOceanEndpoint
+	s.domain = s.conf.Domain
+	return nil
+}
@@ -0,0 +1,65 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDigitalOceanRateLimit(t *testing.T) {
+	reset := time.Now().Add(42 * time.Second).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/domains/example.com/records":
+			io.WriteString(w, `{"domain_records":[{"id":7,"type":"A","name":"host","data":"192.0.2.1","ttl":1800}]}`)
+		case "/domains/example.com/records/7":
+			w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"id":"too_many_requests","message":"API Rate limit exceeded."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := &DigitalOceanService{
+		conf:     &digitalOceanServiceConf{Token: "token", Name: "host.example.com", TTL: 1800},
+		endpoint: server.URL,
+		domain:   "example.com",
+	}
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2"))
+	if err == nil {
+		t.Fatal("Submit should fail when rate limited")
+	}
+	if retryAfter <= 30*time.Second || retryAfter > 42*time.Second {
+		t.Errorf("Retry delay = %s; want about 42s", retryAfter)
+	}
+	if s.recordID != 7 {
+		t.Errorf("Record ID = %d; want 7", s.recordID)
+	}
+}
+
+func TestDigitalOceanMissingDomain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	s := &DigitalOceanService{
+		conf:     &digitalOceanServiceConf{Token: "token", Name: "host.example.com", TTL: 1800},
+		endpoint: server.URL,
+	}
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2"))
+	if err == nil || retryAfter != digitalOceanCooldown {
+		t.Errorf("Submit = %s, %v; want a missing domain error", retryAfter, err)
+	}
+}
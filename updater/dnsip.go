@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const dnsLookupTimeout = 5 * time.Second
+
+// dnsService asks a DNS server that answers a special name with the address
+// the query came from.
+type dnsService struct {
+	name   string
+	server string
+	qname  string
+	// txt selects a TXT query; otherwise, an A or AAAA query is made.
+	txt bool
+}
+
+func (s dnsService) Name() string {
+	return s.name
+}
+
+func (s dnsService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	return s.query(ctx, ARecord, dc)
+}
+
+func (s dnsService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	return s.query(ctx, AAAARecord, dc)
+}
+
+func (s dnsService) query(ctx context.Context, rtype RecordType, dc dialContext) (net.IP, error) {
+	network := "udp4"
+	qtype := dns.TypeA
+	if rtype == AAAARecord {
+		network = "udp6"
+		qtype = dns.TypeAAAA
+	}
+	if s.txt {
+		qtype = dns.TypeTXT
+	}
+
+	conn, err := dc(ctx, network, s.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dnsLookupTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(s.qname), qtype)
+	client := dns.Client{Timeout: time.Until(deadline)}
+	resp, _, err := client.ExchangeWithConn(msg, &dns.Conn{Conn: conn})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, errors.New("server responded with " + dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		var ip net.IP
+		switch v := rr.(type) {
+		case *dns.A:
+			ip = v.A
+		case *dns.AAAA:
+			ip = v.AAAA
+		case *dns.TXT:
+			for _, text := range v.Txt {
+				if ip = net.ParseIP(text); ip != nil {
+					break
+				}
+			}
+		}
+		if ip != nil && matchesRecord(rtype, ip) {
+			return ip, nil
+		}
+	}
+	return nil, errors.New("no address in response")
+}
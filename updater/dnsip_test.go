@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSServiceQuery(t *testing.T) {
+	addr := startDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		from := w.RemoteAddr().(*net.UDPAddr).IP
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		q := req.Question[0]
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 0}
+		switch {
+		case q.Name == "myip.opendns.com." && q.Qtype == dns.TypeA:
+			resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: from})
+		case q.Name == "o-o.myaddr.l.google.com." && q.Qtype == dns.TypeTXT:
+			resp.Answer = append(resp.Answer,
+				&dns.TXT{Hdr: hdr, Txt: []string{"edns0-client-subnet 192.0.2.0/24"}},
+				&dns.TXT{Hdr: hdr, Txt: []string{from.String()}})
+		default:
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+
+	dial := net.Dialer{}
+	for _, service := range []dnsService{
+		{name: "opendns", server: addr, qname: "myip.opendns.com"},
+		{name: "google-dns", server: addr, qname: "o-o.myaddr.l.google.com", txt: true},
+	} {
+		ip, err := service.IPv4Addr(context.Background(), dial.DialContext)
+		if err != nil {
+			t.Errorf("%s: %v", service.Name(), err)
+		} else if !ip.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("%s: address = %s; want 127.0.0.1", service.Name(), ip)
+		}
+	}
+
+	service := dnsService{name: "bad", server: addr, qname: "example.com"}
+	if _, err := service.IPv4Addr(context.Background(), dial.DialContext); err == nil {
+		t.Error("Query for an unknown name should fail")
+	}
+}
@@ -0,0 +1,140 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Gandi LiveDNS API
+// see https://api.gandi.net/docs/livedns/
+
+const (
+	gandiCooldown   = 15 * time.Minute
+	gandiDefaultTTL = 300
+	gandiEndpoint   = "https://api.gandi.net/v5/livedns"
+)
+
+// GandiService implements the Gandi LiveDNS API. If the domain is not
+// configured, it is looked up by name the first time it is needed.
+//
+// LiveDNS replaces all of the values of a record set at once, so records that
+// share a name and type are published together.
+type GandiService struct {
+	conf     *gandiServiceConf
+	endpoint string
+	domain   string
+	set      *rrset
+}
+
+type gandiServiceConf struct {
+	Token  string
+	Domain string
+	Name   string
+	TTL    int
+}
+
+type gandiRRSet struct {
+	Values []string `json:"rrset_values"`
+	TTL    int      `json:"rrset_ttl"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *GandiService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	if s.domain == "" {
+		if err = s.findDomain(ctx); err != nil {
+			retryAfter = s.retryAfter(err)
+			return
+		}
+	}
+
+	path := "/domains/" + url.PathEscape(s.domain) +
+		"/records/" + url.PathEscape(relativeName(s.conf.Name, s.domain)) +
+		"/" + RecordTypeString(rtype)
+	body := gandiRRSet{
+		Values: s.set.values(s, ip),
+		TTL:    s.conf.TTL,
+	}
+	err = s.do(ctx, "PUT", path, body, nil)
+
+	// Forget the domain if it was looked up, in case it moved.
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		s.domain = s.conf.Domain
+	}
+	if err != nil {
+		retryAfter = s.retryAfter(err)
+	}
+	return
+}
+
+// findDomain looks up the LiveDNS domain that contains the record.
+func (s *GandiService) findDomain(ctx context.Context) error {
+	for _, candidate := range zoneCandidates(s.conf.Name) {
+		err := s.do(ctx, "GET", "/domains/"+url.PathEscape(candidate), nil, nil)
+		var httpErr *httpError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		s.domain = candidate
+		return nil
+	}
+	return missingZoneError{s.conf.Name}
+}
+
+func (s *GandiService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.conf.Token)
+	return doJSON(ctx, method, s.endpoint+path, header, in, out)
+}
+
+func (s *GandiService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, gandiCooldown, nil)
+}
+
+func (s *GandiService) rrsetKey() string {
+	return "gandi " + strings.ToLower(s.conf.Name)
+}
+
+func (s *GandiService) joinRRSet(set *rrset) {
+	s.set = set
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *GandiService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *GandiService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *GandiService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &gandiServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = gandiDefaultTTL
+	}
+	s.endpoint = gandiEndpoint
+	s.domain = s.conf.Domain
+	return nil
+}
@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGandiRRSet(t *testing.T) {
+	var put gandiRRSet
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/domains/example.com" && r.Method == "GET":
+			w.Write([]byte(`{"fqdn":"example.com"}`))
+		case r.URL.Path == "/domains/example.com/records/host/AAAA" && r.Method == "PUT":
+			json.NewDecoder(r.Body).Decode(&put)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"message":"DNS Record Created"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"message":"The resource could not be found.","object":"HTTPNotFound","cause":"Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	data := []byte(`
+- service: gandi
+  type: AAAA
+  name: host.example.com
+  token: token
+- service: gandi
+  type: AAAA
+  name: host.example.com
+  token: token
+- service: gandi
+  type: A
+  name: host.example.com
+  token: token`)
+	var updaters Updaters
+	if err := yaml.Unmarshal(data, &updaters); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range updaters {
+		u.Service.(*GandiService).endpoint = server.URL
+	}
+	updaters[0].submitted = net.ParseIP("2001:db8::1")
+	updaters[2].submitted = net.ParseIP("192.0.2.1")
+
+	if _, err := updaters[1].Service.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::2")); err != nil {
+		t.Fatal(err)
+	}
+	want := gandiRRSet{Values: []string{"2001:db8::2", "2001:db8::1"}, TTL: gandiDefaultTTL}
+	if !reflect.DeepEqual(put, want) {
+		t.Errorf("Record set = %+v; want %+v", put, want)
+	}
+}
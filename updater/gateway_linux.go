@@ -0,0 +1,54 @@
+package updater
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Not defined by the syscall package.
+const rtfGateway = 0x2
+
+// defaultGateway reads the IPv4 default gateway, optionally for a particular
+// network interface, from the kernel's routing table.
+func defaultGateway(intname string) (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRouteTable(f, intname)
+}
+
+// parseRouteTable finds the default gateway in the format of /proc/net/route,
+// where addresses are hexadecimal numbers in host byte order.
+func parseRouteTable(r io.Reader, intname string) (net.IP, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Skip the header.
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || (intname != "" && fields[0] != intname) {
+			continue
+		}
+		dest, err1 := strconv.ParseUint(fields[1], 16, 32)
+		gateway, err2 := strconv.ParseUint(fields[2], 16, 32)
+		flags, err3 := strconv.ParseUint(fields[3], 16, 16)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		if dest == 0 && flags&rtfGateway != 0 {
+			ip := make(net.IP, net.IPv4len)
+			*(*uint32)(unsafe.Pointer(&ip[0])) = uint32(gateway)
+			return ip.To16(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default gateway")
+}
@@ -0,0 +1,38 @@
+package updater
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestParseRouteTable(t *testing.T) {
+	// Addresses are in host byte order; this table is for a little-endian host.
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wg0	0000000A	00000000	0001	0	0	0	000000FF	0	0	0
+eth1	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	00000000	FE01A8C0	0003	0	0	200	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+`
+	if !isLittleEndian() {
+		t.Skip("route table is written for a little-endian host")
+	}
+	for intname, want := range map[string]string{
+		"":     "192.168.1.1",
+		"eth0": "192.168.1.254",
+	} {
+		ip, err := parseRouteTable(strings.NewReader(table), intname)
+		if err != nil || !ip.Equal(net.ParseIP(want)) {
+			t.Errorf("Gateway for %q = %s, %v; want %s", intname, ip, err, want)
+		}
+	}
+	if _, err := parseRouteTable(strings.NewReader(table), "wg0"); err == nil {
+		t.Error("wg0 should have no default gateway")
+	}
+}
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package updater
+
+import (
+	"errors"
+	"net"
+)
+
+// defaultGateway is not implemented on this platform, so only UPnP can be used
+// to query the router.
+func defaultGateway(intname string) (net.IP, error) {
+	return nil, errors.New("cannot find the default gateway on this platform")
+}
@@ -0,0 +1,174 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hetzner DNS Console API
+// see https://dns.hetzner.com/api-docs
+
+const (
+	hetznerCooldown = 15 * time.Minute
+	hetznerEndpoint = "https://dns.hetzner.com/api/v1"
+)
+
+// HetznerService implements the Hetzner DNS API. The zone and record
+// identifiers are looked up by name the first time they are needed.
+type HetznerService struct {
+	conf     *hetznerServiceConf
+	endpoint string
+	zoneID   string
+	zoneName string
+	recordID string
+}
+
+type hetznerServiceConf struct {
+	APIToken string `yaml:"api_token"`
+	Zone     string
+	Name     string
+	TTL      int
+
+	CreateIfMissing bool `yaml:"create_if_missing"`
+}
+
+type hetznerRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *HetznerService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	return submitRecord(ctx, s, rtype, ip, s.conf.CreateIfMissing)
+}
+
+// findRecord looks up the zone and record identifiers, if necessary.
+func (s *HetznerService) findRecord(ctx context.Context, rtype RecordType) error {
+	if s.zoneID == "" {
+		candidates := zoneCandidates(s.conf.Name)
+		if s.conf.Zone != "" {
+			candidates = []string{s.conf.Zone}
+		}
+		for _, candidate := range candidates {
+			qs := url.Values{}
+			qs.Add("name", candidate)
+			var resp struct {
+				Zones []struct {
+					ID   string
+					Name string
+				}
+			}
+			err := s.do(ctx, "GET", "/zones?"+qs.Encode(), nil, &resp)
+			var httpErr *httpError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if len(resp.Zones) > 0 {
+				s.zoneID, s.zoneName = resp.Zones[0].ID, resp.Zones[0].Name
+				break
+			}
+		}
+		if s.zoneID == "" {
+			return missingZoneError{s.conf.Name}
+		}
+	}
+
+	if s.recordID == "" {
+		qs := url.Values{}
+		qs.Add("zone_id", s.zoneID)
+		var resp struct {
+			Records []hetznerRecord
+		}
+		if err := s.do(ctx, "GET", "/records?"+qs.Encode(), nil, &resp); err != nil {
+			return err
+		}
+		name := relativeName(s.conf.Name, s.zoneName)
+		for _, record := range resp.Records {
+			if record.Type == RecordTypeString(rtype) && strings.EqualFold(record.Name, name) {
+				s.recordID = record.ID
+				return nil
+			}
+		}
+		return missingRecordError{s.conf.Name, rtype}
+	}
+	return nil
+}
+
+func (s *HetznerService) createRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	var resp struct {
+		Record hetznerRecord
+	}
+	err := s.do(ctx, "POST", "/records", s.record(rtype, ip), &resp)
+	if err == nil {
+		s.recordID = resp.Record.ID
+	}
+	return err
+}
+
+func (s *HetznerService) updateRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	return s.do(ctx, "PUT", "/records/"+url.PathEscape(s.recordID), s.record(rtype, ip), nil)
+}
+
+func (s *HetznerService) record(rtype RecordType, ip net.IP) hetznerRecord {
+	return hetznerRecord{
+		ZoneID: s.zoneID,
+		Type:   RecordTypeString(rtype),
+		Name:   relativeName(s.conf.Name, s.zoneName),
+		Value:  ip.String(),
+		TTL:    s.conf.TTL,
+	}
+}
+
+func (s *HetznerService) forgetRecord() {
+	s.zoneID, s.zoneName, s.recordID = "", "", ""
+}
+
+func (s *HetznerService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Auth-API-Token", s.conf.APIToken)
+	return doJSON(ctx, method, s.endpoint+path, header, in, out)
+}
+
+func (s *HetznerService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, hetznerCooldown, nil)
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *HetznerService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *HetznerService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *HetznerService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &hetznerServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	s.endpoint = hetznerEndpoint
+	return nil
+}
@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHetznerCreateRecord(t *testing.T) {
+	var created hetznerRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Auth-API-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/zones" && r.URL.Query().Get("name") == "example.com":
+			io.WriteString(w, `{"zones":[{"id":"z1","name":"example.com"}]}`)
+		case r.URL.Path == "/zones":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":{"message":"zone not found","code":404}}`)
+		case r.URL.Path == "/records" && r.Method == "GET":
+			io.WriteString(w, `{"records":[{"id":"r1","zone_id":"z1","type":"AAAA","name":"host","value":"2001:db8::1"}]}`)
+		case r.URL.Path == "/records" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&created)
+			io.WriteString(w, `{"record":{"id":"r2","zone_id":"z1","type":"A","name":"host","value":"192.0.2.2"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := &HetznerService{
+		conf:     &hetznerServiceConf{APIToken: "token", Name: "host.example.com"},
+		endpoint: server.URL,
+	}
+	ip := net.ParseIP("192.0.2.2")
+	if _, err := s.Submit(context.Background(), ARecord, ip); err == nil {
+		t.Error("Submit should fail when the record is missing")
+	}
+	if s.zoneID != "z1" {
+		t.Errorf("Zone ID = %q; want z1", s.zoneID)
+	}
+
+	s.conf.CreateIfMissing = true
+	if _, err := s.Submit(context.Background(), ARecord, ip); err != nil {
+		t.Fatal(err)
+	}
+	want := hetznerRecord{ZoneID: "z1", Type: "A", Name: "host", Value: "192.0.2.2"}
+	if created != want {
+		t.Errorf("Created record = %+v; want %+v", created, want)
+	}
+	if s.recordID != "r2" {
+		t.Errorf("Record ID = %q; want r2", s.recordID)
+	}
+}
@@ -0,0 +1,67 @@
+package updater
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// The lifetime of an address that never expires.
+const foreverLifetime = time.Duration(math.MaxInt64)
+
+// ifaddr describes an address assigned to a network interface.
+type ifaddr struct {
+	ip         net.IP
+	temporary  bool
+	deprecated bool
+	preferred  time.Duration
+}
+
+// InterfaceIP selects the best global address of the requested type from the
+// provided network interface.
+func (l IPLookup) InterfaceIP(rtype RecordType, intname string) net.IP {
+	intf, err := net.InterfaceByName(intname)
+	if err != nil {
+		return nil
+	}
+	addrs, err := interfaceAddresses(rtype, intf)
+	if err != nil {
+		return nil
+	}
+	return bestAddress(addrs)
+}
+
+// bestAddress picks an address to publish. Deprecated addresses are skipped,
+// stable addresses are preferred over temporary (privacy) addresses, and among
+// the remainder, the address with the longest preferred lifetime wins.
+func bestAddress(addrs []ifaddr) net.IP {
+	candidates := make([]ifaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if !addr.deprecated {
+			candidates = append(candidates, addr)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.temporary != b.temporary {
+			return !a.temporary
+		}
+		return a.preferred > b.preferred
+	})
+	return candidates[0].ip
+}
+
+func matchesRecord(rtype RecordType, ip net.IP) bool {
+	switch rtype {
+	case ARecord:
+		return isIPv4(ip)
+	case AAAARecord:
+		return isIPv6(ip)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"math"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Not defined by the syscall package.
+const ifaFlags = 0x8
+
+type ifaCacheinfo struct {
+	Prefered uint32
+	Valid    uint32
+	Cstamp   uint32
+	Tstamp   uint32
+}
+
+// interfaceAddresses reads the addresses assigned to an interface, along with
+// their flags and lifetimes, from the kernel's routing socket.
+func interfaceAddresses(rtype RecordType, intf *net.Interface) ([]ifaddr, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]ifaddr, 0)
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWADDR || len(m.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+		ifam := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+		if int(ifam.Index) != intf.Index {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, err
+		}
+
+		addr := ifaddr{preferred: foreverLifetime}
+		flags := uint32(ifam.Flags)
+		var local net.IP
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case syscall.IFA_ADDRESS:
+				addr.ip = net.IP(a.Value)
+			case syscall.IFA_LOCAL:
+				local = net.IP(a.Value)
+			case ifaFlags:
+				if len(a.Value) >= 4 {
+					flags = *(*uint32)(unsafe.Pointer(&a.Value[0]))
+				}
+			case syscall.IFA_CACHEINFO:
+				if len(a.Value) >= int(unsafe.Sizeof(ifaCacheinfo{})) {
+					ci := (*ifaCacheinfo)(unsafe.Pointer(&a.Value[0]))
+					if ci.Prefered != math.MaxUint32 {
+						addr.preferred = time.Duration(ci.Prefered) * time.Second
+					}
+				}
+			}
+		}
+		// For point-to-point links, IFA_ADDRESS is the remote end.
+		if local != nil {
+			addr.ip = local
+		}
+		if addr.ip == nil || !matchesRecord(rtype, addr.ip) || !addr.ip.IsGlobalUnicast() {
+			continue
+		}
+		if flags&(syscall.IFA_F_TENTATIVE|syscall.IFA_F_DADFAILED) != 0 {
+			continue
+		}
+		addr.temporary = flags&syscall.IFA_F_TEMPORARY != 0
+		addr.deprecated = flags&syscall.IFA_F_DEPRECATED != 0 || addr.preferred == 0
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package updater
+
+import "net"
+
+// interfaceAddresses reads the addresses assigned to an interface. Address
+// flags and lifetimes are not available on this platform, so every address is
+// treated as stable.
+func interfaceAddresses(rtype RecordType, intf *net.Interface) ([]ifaddr, error) {
+	addrs := make([]ifaddr, 0)
+	for _, ip := range sourceAddresses(rtype, intf) {
+		addrs = append(addrs, ifaddr{ip: ip, preferred: foreverLifetime})
+	}
+	return addrs, nil
+}
@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBestAddress(t *testing.T) {
+	stable := net.ParseIP("2001:db8::1")
+	temporary := net.ParseIP("2001:db8::2")
+	longer := net.ParseIP("2001:db8::3")
+	deprecated := net.ParseIP("2001:db8::4")
+
+	got := bestAddress([]ifaddr{
+		{ip: temporary, temporary: true, preferred: 2 * time.Hour},
+		{ip: stable, preferred: time.Hour},
+	})
+	if !got.Equal(stable) {
+		t.Errorf("Best address = %s; want stable address %s", got, stable)
+	}
+
+	got = bestAddress([]ifaddr{
+		{ip: stable, preferred: time.Hour},
+		{ip: longer, preferred: foreverLifetime},
+	})
+	if !got.Equal(longer) {
+		t.Errorf("Best address = %s; want longest-lived address %s", got, longer)
+	}
+
+	got = bestAddress([]ifaddr{
+		{ip: deprecated, deprecated: true, preferred: foreverLifetime},
+		{ip: temporary, temporary: true, preferred: time.Hour},
+	})
+	if !got.Equal(temporary) {
+		t.Errorf("Best address = %s; want non-deprecated address %s", got, temporary)
+	}
+
+	got = bestAddress([]ifaddr{{ip: deprecated, deprecated: true}})
+	if got != nil {
+		t.Errorf("Best address = %s; want none", got)
+	}
+}
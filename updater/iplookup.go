@@ -4,29 +4,49 @@ import (
 	"context"
 	"errors"
 	"io"
+	"log"
 	"math/rand"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
 const staleTime = 10 * time.Minute
 
+var stunServices []ipService = []ipService{
+	stunService{name: "stun-cloudflare", server: "stun.cloudflare.com:3478"},
+	stunService{name: "stun-google", server: "stun.l.google.com:19302"}}
+
+var dnsServices []ipService = []ipService{
+	dnsService{name: "opendns", server: "resolver1.opendns.com:53", qname: "myip.opendns.com"},
+	dnsService{name: "google-dns", server: "ns1.google.com:53", qname: "o-o.myaddr.l.google.com", txt: true}}
+
 var ipServices []ipService = []ipService{
 	icanhazipService{},
 	ipifyService{},
 	wtfismyipService{}}
 
+// builtinIPServices lists every service that can be selected by name in the
+// ip_services configuration.
+var builtinIPServices []ipService = append(append(append([]ipService{},
+	ipServices...),
+	stunServices...),
+	dnsServices...)
+
 type ipSource struct {
-	rtype RecordType
-	iname string
+	rtype  RecordType
+	iname  string
+	source IPSource
 }
 
 // IPLookup uses an Internet service to look up the machine's source IP address.
 type IPLookup struct {
 	cache     map[ipSource](net.IP)
 	retrieved map[ipSource](time.Time)
+	routers   map[string]igdControl
+	web       *IPServices
 }
 
 // NewIPLookup initializes a new IPLookup.
@@ -34,69 +54,187 @@ func NewIPLookup() IPLookup {
 	var lookup IPLookup
 	lookup.cache = make(map[ipSource](net.IP))
 	lookup.retrieved = make(map[ipSource](time.Time))
+	lookup.routers = make(map[string]igdControl)
 	return lookup
 }
 
 type dialContext func(context.Context, string, string) (net.Conn, error)
 
 // WebFacingIP looks up the machine's source IP address from the provided network interface.
-func (l IPLookup) WebFacingIP(ctx context.Context, rtype RecordType, intname string) net.IP {
-	key := ipSource{rtype, intname}
+func (l IPLookup) WebFacingIP(ctx context.Context, logger *log.Logger, rtype RecordType, intname string) net.IP {
+	switch {
+	case l.web != nil && l.web.quorumQuery > 0:
+		return l.quorumIP(ctx, logger, l.web, rtype, intname)
+	case l.web != nil:
+		return l.servicesIP(ctx, WebSource, l.web.services, !l.web.sequential, rtype, intname)
+	default:
+		return l.servicesIP(ctx, WebSource, ipServices, true, rtype, intname)
+	}
+}
+
+// StunIP looks up the machine's source IP address from the provided network
+// interface, using only STUN servers.
+func (l IPLookup) StunIP(ctx context.Context, rtype RecordType, intname string) net.IP {
+	return l.servicesIP(ctx, StunSource, stunServices, true, rtype, intname)
+}
+
+// DNSIP looks up the machine's source IP address from the provided network
+// interface, using only DNS servers.
+func (l IPLookup) DNSIP(ctx context.Context, rtype RecordType, intname string) net.IP {
+	return l.servicesIP(ctx, DNSSource, dnsServices, true, rtype, intname)
+}
+
+// servicesIP asks the provided services, in order or else in random order, for
+// the machine's source IP address. The first answer is cached.
+func (l IPLookup) servicesIP(ctx context.Context, source IPSource, services []ipService, shuffle bool, rtype RecordType, intname string) net.IP {
+	key := ipSource{rtype, intname, source}
 	since := l.retrieved[key]
 	if time.Now().After(since.Add(staleTime)) {
-		// Read all source addresses from the selected interface. If we fail to
-		// find any addresses, fall back to automatic selection.
-		var addrs []net.IP
-		if intf, _ := net.InterfaceByName(intname); intf != nil {
-			iaddrs := sourceAddresses(rtype, intf)
-			if len(iaddrs) > 0 {
-				addrs = iaddrs
-			} else {
-				addrs = nil
+		dcs := dialContexts(rtype, intname)
+		for _, service := range orderServices(services, shuffle) {
+			ip, err := askService(ctx, service, rtype, dcs)
+			if err != nil {
+				continue
 			}
-		} else {
-			addrs = nil
+			l.cache[key] = ip
+			l.retrieved[key] = time.Now()
+			return ip
 		}
+	}
+	return l.cache[key]
+}
 
-		// Shuffle our list of IP address services.
-		shuffled := make([]ipService, len(ipServices))
-		copy(shuffled, ipServices)
-		rand.Shuffle(len(shuffled), func(i, j int) {
-			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-		})
+// quorumIP asks several services at once for the machine's source IP address,
+// and only accepts an address that enough of them agree on, and more of them
+// than on any other address. The accepted answer is cached.
+func (l IPLookup) quorumIP(ctx context.Context, logger *log.Logger, web *IPServices, rtype RecordType, intname string) net.IP {
+	key := ipSource{rtype, intname, WebSource}
+	since := l.retrieved[key]
+	if !time.Now().After(since.Add(staleTime)) {
+		return l.cache[key]
+	}
 
-		// Check each source address for each service.
-		for _, service := range shuffled {
-			var dcs []dialContext
-			if addrs == nil {
-				dial := net.Dialer{}
-				dcs = []dialContext{dial.DialContext}
-			} else {
-				dcs = make([]dialContext, 0)
-				for _, addr := range addrs {
-					dcs = append(dcs, dialContextFromAddr(addr))
-				}
+	services := orderServices(web.services, !web.sequential)
+	if len(services) > web.quorumQuery {
+		services = services[:web.quorumQuery]
+	}
+	dcs := dialContexts(rtype, intname)
+	answers := make([]net.IP, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service ipService) {
+			defer wg.Done()
+			answers[i], _ = askService(ctx, service, rtype, dcs)
+		}(i, service)
+	}
+	wg.Wait()
+
+	votes := make(map[string]int)
+	for _, ip := range answers {
+		if ip != nil {
+			votes[ip.String()]++
+		}
+	}
+	var best string
+	var tied bool
+	for addr, n := range votes {
+		if n > votes[best] {
+			best, tied = addr, false
+		} else if n == votes[best] {
+			tied = true
+		}
+	}
+	if len(votes) > 1 || votes[best] < web.quorumAgree {
+		var report []string
+		for i, service := range services {
+			answer := "no answer"
+			if answers[i] != nil {
+				answer = answers[i].String()
 			}
-			for _, dc := range dcs {
-				var (
-					ip  net.IP
-					err error
-				)
-				switch rtype {
-				case ARecord:
-					ip, err = service.IPv4Addr(ctx, dc)
-				case AAAARecord:
-					ip, err = service.IPv6Addr(ctx, dc)
-				}
-				if err != nil {
-					continue
-				}
-				l.cache[key] = ip
-				return ip
+			report = append(report, service.Name()+"="+answer)
+		}
+		logger.Println("IP services disagree:", strings.Join(report, ", "))
+	}
+	if votes[best] < web.quorumAgree {
+		logger.Println("no", RecordTypeString(rtype), "address has a quorum of", web.quorumAgree)
+		return l.cache[key]
+	}
+	// Several addresses can reach a small quorum, but none of them wins.
+	if tied {
+		logger.Println("several", RecordTypeString(rtype), "addresses have a quorum of", web.quorumAgree)
+		return l.cache[key]
+	}
+	ip := net.ParseIP(best)
+	l.cache[key] = ip
+	l.retrieved[key] = time.Now()
+	return ip
+}
+
+// dialContexts returns a dialer for each source address of the selected
+// interface. If we fail to find any addresses, it falls back to automatic
+// selection.
+func dialContexts(rtype RecordType, intname string) []dialContext {
+	if intf, _ := net.InterfaceByName(intname); intf != nil {
+		if addrs := sourceAddresses(rtype, intf); len(addrs) > 0 {
+			dcs := make([]dialContext, 0)
+			for _, addr := range addrs {
+				dcs = append(dcs, dialContextFromAddr(addr))
 			}
+			return dcs
 		}
 	}
-	return l.cache[key]
+	dial := net.Dialer{}
+	return []dialContext{dial.DialContext}
+}
+
+// orderServices copies a list of IP address services, shuffling it if
+// requested.
+func orderServices(services []ipService, shuffle bool) []ipService {
+	ordered := make([]ipService, len(services))
+	copy(ordered, services)
+	if shuffle {
+		rand.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	}
+	return ordered
+}
+
+// askService asks a service for the address of each source address in turn,
+// until one succeeds.
+func askService(ctx context.Context, service ipService, rtype RecordType, dcs []dialContext) (ip net.IP, err error) {
+	for _, dc := range dcs {
+		start := time.Now()
+		switch rtype {
+		case ARecord:
+			ip, err = service.IPv4Addr(ctx, dc)
+		case AAAARecord:
+			ip, err = service.IPv6Addr(ctx, dc)
+		}
+		metrics.observeLookup(service.Name(), time.Since(start), err)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// Invalidate discards the cached addresses for the provided network interface,
+// so that the next lookup queries the Internet services again.
+func (l IPLookup) Invalidate(rtype RecordType, intname string) {
+	for key := range l.retrieved {
+		if key.rtype == rtype && key.iname == intname {
+			delete(l.retrieved, key)
+		}
+	}
+}
+
+// expire discards all of the cached addresses.
+func (l IPLookup) expire() {
+	for key := range l.retrieved {
+		delete(l.retrieved, key)
+	}
 }
 
 func sourceAddresses(rtype RecordType, intf *net.Interface) []net.IP {
@@ -108,16 +246,7 @@ func sourceAddresses(rtype RecordType, intf *net.Interface) []net.IP {
 	for _, addr := range iaddrs {
 		switch v := addr.(type) {
 		case *net.IPNet:
-			var oktype bool
-			switch rtype {
-			case ARecord:
-				oktype = isIPv4(v.IP)
-			case AAAARecord:
-				oktype = isIPv6(v.IP)
-			default:
-				oktype = false
-			}
-			if oktype && v.IP.IsGlobalUnicast() {
+			if matchesRecord(rtype, v.IP) && v.IP.IsGlobalUnicast() {
 				addrs = append(addrs, v.IP)
 			}
 		}
@@ -156,49 +285,74 @@ func dialContextFromAddr(addr net.IP) dialContext {
 }
 
 func isIPv4(ip net.IP) bool {
-	return len(ip) == 4
+	return ip.To4() != nil
 }
 
 func isIPv6(ip net.IP) bool {
-	return len(ip) == 16
+	return len(ip) == net.IPv6len && ip.To4() == nil
 }
 
 type ipService interface {
+	Name() string
 	IPv4Addr(context.Context, dialContext) (net.IP, error)
 	IPv6Addr(context.Context, dialContext) (net.IP, error)
 }
 
 type icanhazipService struct{}
 
+func (icanhazipService) Name() string {
+	return "icanhazip"
+}
+
 func (icanhazipService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://v4.icanhazip.com", dc)
+	return retrieve(ctx, ARecord, "https://v4.icanhazip.com", dc)
 }
 
 func (icanhazipService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://v6.icanhazip.com", dc)
+	return retrieve(ctx, AAAARecord, "https://v6.icanhazip.com", dc)
 }
 
 type ipifyService struct{}
 
+func (ipifyService) Name() string {
+	return "ipify"
+}
+
 func (ipifyService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://api.ipify.org", dc)
+	return retrieve(ctx, ARecord, "https://api.ipify.org", dc)
 }
 
 func (ipifyService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://api6.ipify.org", dc)
+	return retrieve(ctx, AAAARecord, "https://api6.ipify.org", dc)
 }
 
 type wtfismyipService struct{}
 
+func (wtfismyipService) Name() string {
+	return "wtfismyip"
+}
+
 func (wtfismyipService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://ipv4.wtfismyip.com/text", dc)
+	return retrieve(ctx, ARecord, "https://ipv4.wtfismyip.com/text", dc)
 }
 
 func (wtfismyipService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
-	return retrieve(ctx, "https://ipv6.wtfismyip.com/text", dc)
+	return retrieve(ctx, AAAARecord, "https://ipv6.wtfismyip.com/text", dc)
 }
 
-func retrieve(ctx context.Context, url string, dc dialContext) (net.IP, error) {
+func retrieve(ctx context.Context, rtype RecordType, url string, dc dialContext) (net.IP, error) {
+	body, err := fetch(ctx, url, nil, dc)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || !matchesRecord(rtype, ip) {
+		return nil, errors.New("response has no " + RecordTypeString(rtype) + " address")
+	}
+	return ip, nil
+}
+
+func fetch(ctx context.Context, url string, header http.Header, dc dialContext) ([]byte, error) {
 	client := &http.Client{
 		Transport: &http.Transport{
 			DialContext: dc,
@@ -208,6 +362,9 @@ func retrieve(ctx context.Context, url string, dc dialContext) (net.IP, error) {
 	if err != nil {
 		return nil, err
 	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -218,10 +375,5 @@ func retrieve(ctx context.Context, url string, dc dialContext) (net.IP, error) {
 		return nil, errors.New("bad response code")
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	ip := strings.TrimSpace(string(body))
-	return net.ParseIP(ip), nil
+	return io.ReadAll(resp.Body)
 }
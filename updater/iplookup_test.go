@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRetrieveGarbage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			io.WriteString(w, "<html>Service Unavailable</html>")
+		case "/ipv6":
+			io.WriteString(w, "2001:db8::1\n")
+		}
+	}))
+	defer server.Close()
+
+	dial := net.Dialer{}
+	for _, path := range []string{"/html", "/ipv6"} {
+		ip, err := retrieve(context.Background(), ARecord, server.URL+path, dial.DialContext)
+		if err == nil {
+			t.Errorf("%s: retrieve = %v; want an error", path, ip)
+		}
+	}
+	ip, err := retrieve(context.Background(), AAAARecord, server.URL+"/ipv6", dial.DialContext)
+	if err != nil || !ip.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("retrieve = %v, %v; want 2001:db8::1", ip, err)
+	}
+}
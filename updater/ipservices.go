@@ -0,0 +1,246 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IPServices configures the services that look up the web-facing IP address,
+// and whether several of them must agree on it.
+type IPServices struct {
+	services    []ipService
+	sequential  bool
+	quorumQuery int
+	quorumAgree int
+	fingerprint string
+}
+
+type ipServicesConf struct {
+	Order    string
+	Services []ipServiceConf
+	Quorum   *struct {
+		Query int
+		Agree int
+	}
+}
+
+type ipServiceConf struct {
+	Builtin  string
+	Name     string
+	IPv4URL  string `yaml:"ipv4_url"`
+	IPv6URL  string `yaml:"ipv6_url"`
+	Regex    string
+	JSONPath string `yaml:"json_path"`
+	Headers  map[string]string
+}
+
+// UnmarshalYAML constructs a list of IP address services from a YAML
+// configuration.
+func (s *IPServices) UnmarshalYAML(value *yaml.Node) error {
+	value, err := resolveSecrets(value)
+	if err != nil {
+		return err
+	}
+	var conf ipServicesConf
+	if err := value.Decode(&conf); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(conf.Order) {
+	case "", "random":
+		s.sequential = false
+	case "sequential":
+		s.sequential = true
+	default:
+		return errors.New("unknown IP service order")
+	}
+
+	// Leaving out the list entirely selects the default web services.
+	if conf.Services == nil {
+		s.services = ipServices
+	} else if len(conf.Services) == 0 {
+		return errors.New("no IP services")
+	}
+	for _, sc := range conf.Services {
+		service, err := newIPService(sc)
+		if err != nil {
+			return err
+		}
+		s.services = append(s.services, service)
+	}
+
+	if q := conf.Quorum; q != nil {
+		if q.Query <= 0 {
+			q.Query = len(s.services)
+		}
+		if q.Agree <= 0 {
+			q.Agree = q.Query/2 + 1
+		}
+		if q.Query > len(s.services) {
+			return errors.New("quorum queries more IP services than are configured")
+		}
+		if q.Agree > q.Query {
+			return errors.New("quorum requires more IP services to agree than it queries")
+		}
+		s.quorumQuery = q.Query
+		s.quorumAgree = q.Agree
+	}
+
+	enc, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(enc)
+	s.fingerprint = hex.EncodeToString(sum[:8])
+	return nil
+}
+
+func newIPService(conf ipServiceConf) (ipService, error) {
+	if conf.Builtin != "" {
+		for _, service := range builtinIPServices {
+			if strings.EqualFold(service.Name(), conf.Builtin) {
+				return service, nil
+			}
+		}
+		return nil, errors.New("unknown built-in IP service " + conf.Builtin)
+	}
+
+	if conf.IPv4URL == "" && conf.IPv6URL == "" {
+		return nil, errors.New("IP service requires ipv4_url or ipv6_url")
+	}
+	service := httpService{
+		name:   conf.Name,
+		url4:   conf.IPv4URL,
+		url6:   conf.IPv6URL,
+		header: http.Header{},
+	}
+	if service.name == "" {
+		for _, raw := range []string{conf.IPv4URL, conf.IPv6URL} {
+			if u, err := url.Parse(raw); err == nil && u.Host != "" {
+				service.name = u.Host
+				break
+			}
+		}
+	}
+	for name, value := range conf.Headers {
+		service.header.Set(name, value)
+	}
+	if conf.Regex != "" {
+		pattern, err := regexp.Compile(conf.Regex)
+		if err != nil {
+			return nil, err
+		}
+		service.pattern = pattern
+	}
+	if conf.JSONPath != "" {
+		service.jsonPath = strings.Split(strings.TrimPrefix(conf.JSONPath, "$."), ".")
+	}
+	return service, nil
+}
+
+// equal determines whether two configurations define the same services. A nil
+// configuration stands for the built-in services.
+func (s *IPServices) equal(other *IPServices) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.fingerprint == other.fingerprint
+}
+
+// httpService is a user-defined web service that reports the address of its
+// clients.
+type httpService struct {
+	name     string
+	url4     string
+	url6     string
+	header   http.Header
+	pattern  *regexp.Regexp
+	jsonPath []string
+}
+
+func (s httpService) Name() string {
+	return s.name
+}
+
+func (s httpService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	if s.url4 == "" {
+		return nil, errors.New("no IPv4 URL")
+	}
+	return s.query(ctx, ARecord, s.url4, dc)
+}
+
+func (s httpService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	if s.url6 == "" {
+		return nil, errors.New("no IPv6 URL")
+	}
+	return s.query(ctx, AAAARecord, s.url6, dc)
+}
+
+func (s httpService) query(ctx context.Context, rtype RecordType, url string, dc dialContext) (net.IP, error) {
+	body, err := fetch(ctx, url, s.header, dc)
+	if err != nil {
+		return nil, err
+	}
+	text := string(body)
+	if s.jsonPath != nil {
+		if text, err = extractJSONPath(body, s.jsonPath); err != nil {
+			return nil, err
+		}
+	}
+	if s.pattern != nil {
+		match := s.pattern.FindStringSubmatch(text)
+		switch {
+		case match == nil:
+			return nil, errors.New("response does not match the regex")
+		case len(match) > 1:
+			text = match[1]
+		default:
+			text = match[0]
+		}
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(text))
+	if ip == nil || !matchesRecord(rtype, ip) {
+		return nil, errors.New("response has no " + RecordTypeString(rtype) + " address")
+	}
+	return ip, nil
+}
+
+// extractJSONPath reads the string at a path of object keys and array indices,
+// such as "data.addresses.0".
+func extractJSONPath(data []byte, path []string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", errors.New("no element " + key + " in JSON response")
+			}
+			value = v[i]
+		default:
+			return "", errors.New("no element " + key + " in JSON response")
+		}
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", errors.New("JSON path does not lead to a string")
+	}
+	return text, nil
+}
@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestIPServicesConfig(t *testing.T) {
+	var s IPServices
+	data := []byte(`
+order: sequential
+services:
+  - builtin: ipify
+  - ipv4_url: https://echo.example.com/v4
+    regex: 'addr=(\S+)'
+    headers:
+      Authorization: Bearer token
+  - builtin: stun-google
+  - builtin: opendns`)
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.sequential || len(s.services) != 4 {
+		t.Fatalf("Services = %+v", s)
+	}
+	if _, ok := s.services[2].(stunService); !ok {
+		t.Errorf("Third service = %T; want stunService", s.services[2])
+	}
+	if _, ok := s.services[3].(dnsService); !ok {
+		t.Errorf("Fourth service = %T; want dnsService", s.services[3])
+	}
+	if _, ok := s.services[0].(ipifyService); !ok {
+		t.Errorf("First service = %T; want ipifyService", s.services[0])
+	}
+	if name := s.services[1].Name(); name != "echo.example.com" {
+		t.Errorf("Second service name = %q; want echo.example.com", name)
+	}
+
+	var defaults IPServices
+	if err := yaml.Unmarshal([]byte("order: sequential"), &defaults); err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range defaults.services {
+		switch service.(type) {
+		case icanhazipService, ipifyService, wtfismyipService:
+		default:
+			t.Errorf("Default services include %s", service.Name())
+		}
+	}
+
+	for _, bad := range []string{
+		"services: [{builtin: nonexistent}]",
+		"services: [{name: empty}]",
+		"order: alphabetical\nservices: [{builtin: ipify}]",
+		"services: []",
+		"quorum: {query: 4}\nservices: [{builtin: ipify}, {builtin: icanhazip}]",
+		"quorum: {query: 1, agree: 2}",
+	} {
+		var s IPServices
+		if err := yaml.Unmarshal([]byte(bad), &s); err == nil {
+			t.Errorf("Configuration should be rejected: %s", bad)
+		}
+	}
+}
+
+func TestHTTPServiceExtraction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/json":
+			io.WriteString(w, `{"client":{"addresses":["192.0.2.1","2001:db8::1"]}}`)
+		case "/html":
+			io.WriteString(w, `<p>Your address is <b>192.0.2.2</b></p>`)
+		case "/garbage":
+			io.WriteString(w, `<html>rate limited</html>`)
+		}
+	}))
+	defer server.Close()
+
+	dial := net.Dialer{}
+	for _, test := range []struct {
+		conf ipServiceConf
+		want string
+	}{
+		{ipServiceConf{IPv4URL: server.URL + "/json", JSONPath: "client.addresses.0"}, "192.0.2.1"},
+		{ipServiceConf{IPv4URL: server.URL + "/html", Regex: `<b>([0-9.]+)</b>`}, "192.0.2.2"},
+		{ipServiceConf{IPv4URL: server.URL + "/garbage"}, ""},
+		{ipServiceConf{IPv6URL: server.URL + "/json"}, ""},
+	} {
+		test.conf.Headers = map[string]string{"X-Token": "secret"}
+		service, err := newIPService(test.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip, err := service.IPv4Addr(context.Background(), dial.DialContext)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%+v: address = %s; want an error", test.conf, ip)
+			}
+		} else if err != nil || !ip.Equal(net.ParseIP(test.want)) {
+			t.Errorf("%+v: address = %s, %v; want %s", test.conf, ip, err, test.want)
+		}
+	}
+}
+
+func TestSequentialIPServices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/first":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/second":
+			io.WriteString(w, "192.0.2.2\n")
+		case "/third":
+			io.WriteString(w, "192.0.2.3\n")
+		}
+	}))
+	defer server.Close()
+
+	var s IPServices
+	data := []byte(`
+order: sequential
+services:
+  - ipv4_url: ` + server.URL + `/first
+  - ipv4_url: ` + server.URL + `/second
+  - ipv4_url: ` + server.URL + `/third`)
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	lookup := NewIPLookup()
+	lookup.web = &s
+	for i := 0; i < 5; i++ {
+		lookup.Invalidate(ARecord, "")
+		if ip := lookup.WebFacingIP(context.Background(), log.New(io.Discard, "", 0), ARecord, ""); !ip.Equal(net.ParseIP("192.0.2.2")) {
+			t.Fatalf("Address = %s; want 192.0.2.2", ip)
+		}
+	}
+}
+
+func TestQuorumIPServices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/honest":
+			io.WriteString(w, "192.0.2.1\n")
+		case "/liar":
+			io.WriteString(w, "198.51.100.1\n")
+		case "/garbage":
+			io.WriteString(w, "<html>oops</html>")
+		}
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		paths []string
+		agree int
+		want  string
+		warn  bool
+	}{
+		{[]string{"/honest", "/honest", "/honest"}, 2, "192.0.2.1", false},
+		{[]string{"/honest", "/liar", "/honest"}, 2, "192.0.2.1", true},
+		{[]string{"/honest", "/liar", "/garbage"}, 2, "", true},
+		{[]string{"/honest", "/liar", "/honest", "/liar"}, 2, "", true},
+		{[]string{"/honest", "/liar", "/garbage"}, 1, "", true},
+	} {
+		data := "quorum: {agree: " + strconv.Itoa(test.agree) + "}\nservices:\n"
+		for i, path := range test.paths {
+			data += "  - {name: s" + string(rune('0'+i)) + ", ipv4_url: " + server.URL + path + "}\n"
+		}
+		var s IPServices
+		if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+			t.Fatal(err)
+		}
+		var logged bytes.Buffer
+		lookup := NewIPLookup()
+		lookup.web = &s
+		ip := lookup.WebFacingIP(context.Background(), log.New(&logged, "", 0), ARecord, "")
+		if test.want == "" && ip != nil {
+			t.Errorf("%v: address = %s; want no quorum", test.paths, ip)
+		} else if test.want != "" && !ip.Equal(net.ParseIP(test.want)) {
+			t.Errorf("%v: address = %s; want %s", test.paths, ip, test.want)
+		}
+		if warned := logged.Len() > 0; warned != test.warn {
+			t.Errorf("%v: logged %q; want a warning = %t", test.paths, logged.String(), test.warn)
+		}
+	}
+}
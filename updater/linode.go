@@ -0,0 +1,192 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Linode API v4
+// see https://www.linode.com/docs/api/domains/
+
+const (
+	linodeCooldown = 15 * time.Minute
+	linodeEndpoint = "https://api.linode.com/v4"
+)
+
+// LinodeService implements the Linode Domains API. The domain and record
+// identifiers are looked up by name the first time they are needed.
+type LinodeService struct {
+	conf     *linodeServiceConf
+	endpoint string
+	domainID int
+	domain   string
+	recordID int
+}
+
+type linodeServiceConf struct {
+	Token  string
+	Domain string
+	Name   string
+	TTL    int
+
+	CreateIfMissing bool `yaml:"create_if_missing"`
+}
+
+type linodeRecord struct {
+	ID     int    `json:"id,omitempty"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	TTLSec int    `json:"ttl_sec,omitempty"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *LinodeService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	return submitRecord(ctx, s, rtype, ip, s.conf.CreateIfMissing)
+}
+
+// findRecord looks up the domain and record identifiers, if necessary.
+func (s *LinodeService) findRecord(ctx context.Context, rtype RecordType) error {
+	if s.domainID == 0 {
+		candidates := zoneCandidates(s.conf.Name)
+		if s.conf.Domain != "" {
+			candidates = []string{s.conf.Domain}
+		}
+		for _, candidate := range candidates {
+			filter, _ := json.Marshal(map[string]string{"domain": candidate})
+			header := http.Header{}
+			header.Set("X-Filter", string(filter))
+			var resp struct {
+				Data []struct {
+					ID     int
+					Domain string
+				}
+			}
+			if err := s.do(ctx, "GET", "/domains", header, nil, &resp); err != nil {
+				return err
+			}
+			if len(resp.Data) > 0 {
+				s.domainID, s.domain = resp.Data[0].ID, resp.Data[0].Domain
+				break
+			}
+		}
+		if s.domainID == 0 {
+			return missingZoneError{s.conf.Name}
+		}
+	}
+
+	if s.recordID == 0 {
+		name := s.recordName()
+		for page, pages := 1, 1; page <= pages; page++ {
+			qs := url.Values{}
+			qs.Add("page", strconv.Itoa(page))
+			qs.Add("page_size", "500")
+			var resp struct {
+				Data  []linodeRecord
+				Pages int
+			}
+			path := "/domains/" + strconv.Itoa(s.domainID) + "/records?" + qs.Encode()
+			if err := s.do(ctx, "GET", path, nil, nil, &resp); err != nil {
+				return err
+			}
+			for _, record := range resp.Data {
+				if record.Type == RecordTypeString(rtype) && strings.EqualFold(record.Name, name) {
+					s.recordID = record.ID
+					return nil
+				}
+			}
+			pages = resp.Pages
+		}
+		return missingRecordError{s.conf.Name, rtype}
+	}
+	return nil
+}
+
+func (s *LinodeService) createRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	var resp linodeRecord
+	path := "/domains/" + strconv.Itoa(s.domainID) + "/records"
+	err := s.do(ctx, "POST", path, nil, s.record(rtype, ip), &resp)
+	if err == nil {
+		s.recordID = resp.ID
+	}
+	return err
+}
+
+func (s *LinodeService) updateRecord(ctx context.Context, rtype RecordType, ip net.IP) error {
+	path := "/domains/" + strconv.Itoa(s.domainID) + "/records/" + strconv.Itoa(s.recordID)
+	return s.do(ctx, "PUT", path, nil, s.record(rtype, ip), nil)
+}
+
+func (s *LinodeService) record(rtype RecordType, ip net.IP) linodeRecord {
+	return linodeRecord{
+		Type:   RecordTypeString(rtype),
+		Name:   s.recordName(),
+		Target: ip.String(),
+		TTLSec: s.conf.TTL,
+	}
+}
+
+func (s *LinodeService) forgetRecord() {
+	s.domainID, s.domain, s.recordID = 0, "", 0
+}
+
+// recordName returns the name of the record relative to its domain. Linode
+// represents the domain itself with an empty name.
+func (s *LinodeService) recordName() string {
+	name := relativeName(s.conf.Name, s.domain)
+	if name == "@" {
+		return ""
+	}
+	return name
+}
+
+func (s *LinodeService) do(ctx context.Context, method, path string, header http.Header, in, out interface{}) error {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Authorization", "Bearer "+s.conf.Token)
+	return doJSON(ctx, method, s.endpoint+path, header, in, out)
+}
+
+// retryAfter determines when to try again after an error. Linode reports how
+// long to wait in the Retry-After header.
+func (s *LinodeService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, linodeCooldown, func(header http.Header) time.Duration {
+		return parseRetryAfter(header.Get("Retry-After"))
+	})
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *LinodeService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *LinodeService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *LinodeService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &linodeServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	s.endpoint = linodeEndpoint
+	return nil
+}
@@ -0,0 +1,200 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeLinode mirrors the parts of the Linode Domains API used by the service.
+// It returns one record per page to exercise pagination.
+type fakeLinode struct {
+	domains map[int]string
+	records map[int][]linodeRecord
+	nextID  int
+}
+
+func newFakeLinode() *fakeLinode {
+	return &fakeLinode{
+		domains: map[int]string{1: "example.com"},
+		records: map[int][]linodeRecord{1: {
+			{ID: 10, Type: "A", Name: "www", Target: "192.0.2.10"},
+			{ID: 11, Type: "AAAA", Name: "host", Target: "2001:db8::1"},
+			{ID: 12, Type: "A", Name: "host", Target: "192.0.2.1", TTLSec: 300},
+		}},
+		nextID: 100,
+	}
+}
+
+func (f *fakeLinode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeError := func(status int, reason string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []map[string]string{{"reason": reason}},
+		})
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		writeError(http.StatusUnauthorized, "Invalid Token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "domains" && r.Method == "GET":
+		var filter struct{ Domain string }
+		json.Unmarshal([]byte(r.Header.Get("X-Filter")), &filter)
+		data := []map[string]interface{}{}
+		for id, domain := range f.domains {
+			if filter.Domain == "" || filter.Domain == domain {
+				data = append(data, map[string]interface{}{"id": id, "domain": domain})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "page": 1, "pages": 1, "results": len(data)})
+		return
+	case len(parts) >= 3 && parts[0] == "domains" && parts[2] == "records":
+		domainID, _ := strconv.Atoi(parts[1])
+		records, ok := f.records[domainID]
+		if !ok {
+			writeError(http.StatusNotFound, "Not found")
+			return
+		}
+		if len(parts) == 3 && r.Method == "GET" {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page < 1 || page > len(records) {
+				writeError(http.StatusBadRequest, "Invalid page")
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data":    records[page-1 : page],
+				"page":    page,
+				"pages":   len(records),
+				"results": len(records),
+			})
+			return
+		}
+		var body linodeRecord
+		if r.Method == "POST" || r.Method == "PUT" {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		if len(parts) == 3 && r.Method == "POST" {
+			body.ID = f.nextID
+			f.nextID++
+			f.records[domainID] = append(records, body)
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+		if len(parts) == 4 && r.Method == "PUT" {
+			recordID, _ := strconv.Atoi(parts[3])
+			for i, record := range records {
+				if record.ID == recordID {
+					record.Target, record.TTLSec = body.Target, body.TTLSec
+					records[i] = record
+					json.NewEncoder(w).Encode(record)
+					return
+				}
+			}
+		}
+	}
+	writeError(http.StatusNotFound, "Not found")
+}
+
+func (f *fakeLinode) find(domainID int, rtype, name string) *linodeRecord {
+	for _, record := range f.records[domainID] {
+		if record.Type == rtype && record.Name == name {
+			return &record
+		}
+	}
+	return nil
+}
+
+func newTestLinodeService(url string, conf linodeServiceConf) *LinodeService {
+	if conf.Token == "" {
+		conf.Token = "token"
+	}
+	return &LinodeService{conf: &conf, endpoint: url}
+}
+
+func TestLinodeUpdate(t *testing.T) {
+	fake := newFakeLinode()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestLinodeService(server.URL, linodeServiceConf{Name: "host.example.com", TTL: 3600})
+	if _, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2")); err != nil {
+		t.Fatal(err)
+	}
+	if s.domainID != 1 || s.recordID != 12 {
+		t.Errorf("IDs = %d, %d; want 1, 12", s.domainID, s.recordID)
+	}
+	record := fake.find(1, "A", "host")
+	if record.Target != "192.0.2.2" || record.TTLSec != 3600 {
+		t.Errorf("Record = %+v", *record)
+	}
+	if other := fake.find(1, "AAAA", "host"); other.Target != "2001:db8::1" {
+		t.Errorf("AAAA record was changed: %+v", *other)
+	}
+}
+
+func TestLinodeCreateIfMissing(t *testing.T) {
+	fake := newFakeLinode()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestLinodeService(server.URL, linodeServiceConf{Name: "example.com"})
+	retryAfter, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::2"))
+	if err == nil || retryAfter != linodeCooldown {
+		t.Errorf("Submit = %s, %v; want a missing record error", retryAfter, err)
+	}
+
+	s.conf.CreateIfMissing = true
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::2")); err != nil {
+		t.Fatal(err)
+	}
+	record := fake.find(1, "AAAA", "")
+	if record == nil || record.Target != "2001:db8::2" {
+		t.Fatalf("Record at the apex was not created: %+v", fake.records[1])
+	}
+	if s.recordID != record.ID {
+		t.Errorf("Record ID = %d; want %d", s.recordID, record.ID)
+	}
+}
+
+func TestLinodeDeletedRecord(t *testing.T) {
+	fake := newFakeLinode()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestLinodeService(server.URL, linodeServiceConf{Name: "host.example.com"})
+	s.domainID, s.domain, s.recordID = 1, "example.com", 99
+	if _, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2")); err == nil {
+		t.Fatal("Submit should fail for a deleted record")
+	}
+	if s.domainID != 0 || s.recordID != 0 {
+		t.Errorf("IDs = %d, %d; want them to be forgotten", s.domainID, s.recordID)
+	}
+	if _, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2")); err != nil {
+		t.Fatal(err)
+	}
+	if s.recordID != 12 {
+		t.Errorf("Record ID = %d; want 12", s.recordID)
+	}
+}
+
+func TestLinodeUnauthorized(t *testing.T) {
+	server := httptest.NewServer(newFakeLinode())
+	defer server.Close()
+
+	s := newTestLinodeService(server.URL, linodeServiceConf{Token: "wrong", Name: "host.example.com"})
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.2"))
+	if err == nil || !strings.Contains(err.Error(), "Invalid Token") {
+		t.Errorf("Submit error = %v; want Invalid Token", err)
+	}
+	if retryAfter != linodeCooldown {
+		t.Errorf("Retry delay = %s; want %s", retryAfter, linodeCooldown)
+	}
+}
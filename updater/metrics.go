@@ -0,0 +1,195 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var metrics = newMetricsRegistry()
+
+// metricsRegistry collects statistics about records and IP address lookups for
+// export to Prometheus.
+type metricsRegistry struct {
+	mu      sync.Mutex
+	records map[recordKey]*recordMetrics
+	lookups map[string]*lookupMetrics
+}
+
+type recordKey struct {
+	record string
+	rtype  string
+}
+
+type recordMetrics struct {
+	lastSuccess time.Time
+	successes   int
+	failures    int
+	published   net.IP
+	tryAfter    time.Time
+}
+
+type lookupMetrics struct {
+	count    int
+	seconds  float64
+	failures int
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		records: make(map[recordKey]*recordMetrics),
+		lookups: make(map[string]*lookupMetrics),
+	}
+}
+
+// record returns the metrics for an updater. The caller must hold the lock.
+func (m *metricsRegistry) record(u *Updater) *recordMetrics {
+	key := recordKey{u.Service.Identifier(), RecordTypeString(u.Type)}
+	rm, ok := m.records[key]
+	if !ok {
+		rm = &recordMetrics{}
+		m.records[key] = rm
+	}
+	return rm
+}
+
+// observeRecord registers an updater's current state, including state that was
+// restored from a state file.
+func (m *metricsRegistry) observeRecord(u *Updater) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm := m.record(u)
+	if u.submittedAt.After(rm.lastSuccess) {
+		rm.lastSuccess = u.submittedAt
+	}
+	rm.published = u.submitted
+	rm.tryAfter = u.tryAfter
+}
+
+// observeSubmission records the outcome of a submission.
+func (m *metricsRegistry) observeSubmission(u *Updater, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm := m.record(u)
+	if err != nil {
+		rm.failures++
+	} else {
+		rm.successes++
+		rm.lastSuccess = time.Now()
+		rm.published = u.submitted
+	}
+	rm.tryAfter = u.tryAfter
+}
+
+// observeLookup records the outcome of a query to an IP address service.
+func (m *metricsRegistry) observeLookup(service string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lm, ok := m.lookups[service]
+	if !ok {
+		lm = &lookupMetrics{}
+		m.lookups[service] = lm
+	}
+	lm.count++
+	lm.seconds += elapsed.Seconds()
+	if err != nil {
+		lm.failures++
+	}
+}
+
+// MetricsHandler serves the collected metrics in the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.write(w)
+	})
+}
+
+func (m *metricsRegistry) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]recordKey, 0, len(m.records))
+	for key := range m.records {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].record != keys[j].record {
+			return keys[i].record < keys[j].record
+		}
+		return keys[i].rtype < keys[j].rtype
+	})
+	services := make([]string, 0, len(m.lookups))
+	for service := range m.lookups {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	now := time.Now()
+
+	fmt.Fprintln(w, "# HELP dsddns_record_last_success_timestamp_seconds Time of the last successful submission.")
+	fmt.Fprintln(w, "# TYPE dsddns_record_last_success_timestamp_seconds gauge")
+	for _, key := range keys {
+		rm := m.records[key]
+		if !rm.lastSuccess.IsZero() {
+			fmt.Fprintf(w, "dsddns_record_last_success_timestamp_seconds{%s} %d\n", key.labels(), rm.lastSuccess.Unix())
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP dsddns_record_submissions_total Number of submissions by result.")
+	fmt.Fprintln(w, "# TYPE dsddns_record_submissions_total counter")
+	for _, key := range keys {
+		rm := m.records[key]
+		fmt.Fprintf(w, "dsddns_record_submissions_total{%s,result=\"success\"} %d\n", key.labels(), rm.successes)
+		fmt.Fprintf(w, "dsddns_record_submissions_total{%s,result=\"failure\"} %d\n", key.labels(), rm.failures)
+	}
+
+	fmt.Fprintln(w, "# HELP dsddns_record_published_info The address most recently published for the record.")
+	fmt.Fprintln(w, "# TYPE dsddns_record_published_info gauge")
+	for _, key := range keys {
+		rm := m.records[key]
+		if rm.published != nil {
+			fmt.Fprintf(w, "dsddns_record_published_info{%s,ip=\"%s\"} 1\n", key.labels(), rm.published.String())
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP dsddns_record_retry_seconds Time until the next submission attempt is allowed.")
+	fmt.Fprintln(w, "# TYPE dsddns_record_retry_seconds gauge")
+	for _, key := range keys {
+		rm := m.records[key]
+		retry := rm.tryAfter.Sub(now).Seconds()
+		if retry < 0 {
+			retry = 0
+		}
+		fmt.Fprintf(w, "dsddns_record_retry_seconds{%s} %g\n", key.labels(), retry)
+	}
+
+	fmt.Fprintln(w, "# HELP dsddns_ip_lookup_duration_seconds Time taken to query an IP address service.")
+	fmt.Fprintln(w, "# TYPE dsddns_ip_lookup_duration_seconds summary")
+	for _, service := range services {
+		lm := m.lookups[service]
+		fmt.Fprintf(w, "dsddns_ip_lookup_duration_seconds_sum{service=\"%s\"} %g\n", escapeLabel(service), lm.seconds)
+		fmt.Fprintf(w, "dsddns_ip_lookup_duration_seconds_count{service=\"%s\"} %d\n", escapeLabel(service), lm.count)
+	}
+
+	fmt.Fprintln(w, "# HELP dsddns_ip_lookup_failures_total Number of failed queries to an IP address service.")
+	fmt.Fprintln(w, "# TYPE dsddns_ip_lookup_failures_total counter")
+	for _, service := range services {
+		lm := m.lookups[service]
+		fmt.Fprintf(w, "dsddns_ip_lookup_failures_total{service=\"%s\"} %d\n", escapeLabel(service), lm.failures)
+	}
+}
+
+func (k recordKey) labels() string {
+	return fmt.Sprintf("record=\"%s\",type=\"%s\"", escapeLabel(k.record), escapeLabel(k.rtype))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,68 @@
+package updater
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := newMetricsRegistry()
+	u := &Updater{
+		Type:    AAAARecord,
+		Service: &DuckService{conf: &duckServiceConf{Subname: "dsddns"}},
+	}
+	u.submitted = net.ParseIP("2001:db8::1")
+	m.observeSubmission(u, nil)
+	u.tryAfter = time.Now().Add(time.Hour)
+	m.observeSubmission(u, errors.New("bad response code"))
+	m.observeLookup(`odd"name`, time.Second, errors.New("timeout"))
+
+	var b strings.Builder
+	m.write(&b)
+	got := b.String()
+	for _, want := range []string{
+		`dsddns_record_submissions_total{record="dsddns.duckdns.org",type="AAAA",result="success"} 1`,
+		`dsddns_record_submissions_total{record="dsddns.duckdns.org",type="AAAA",result="failure"} 1`,
+		`dsddns_record_published_info{record="dsddns.duckdns.org",type="AAAA",ip="2001:db8::1"} 1`,
+		`dsddns_ip_lookup_duration_seconds_count{service="odd\"name"} 1`,
+		`dsddns_ip_lookup_failures_total{service="odd\"name"} 1`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("Metrics output is missing %s", want)
+		}
+	}
+}
+
+func TestMetricsObserveRecord(t *testing.T) {
+	m := newMetricsRegistry()
+	u := &Updater{
+		Type:    ARecord,
+		Service: &DuckService{conf: &duckServiceConf{Subname: "dsddns"}},
+	}
+	u.submitted = net.ParseIP("192.0.2.1")
+	u.submittedAt = time.Unix(1600000000, 0)
+	m.observeRecord(u)
+
+	var b strings.Builder
+	m.write(&b)
+	for _, want := range []string{
+		`dsddns_record_last_success_timestamp_seconds{record="dsddns.duckdns.org",type="A"} 1600000000`,
+		`dsddns_record_published_info{record="dsddns.duckdns.org",type="A",ip="192.0.2.1"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("Metrics output is missing %s", want)
+		}
+	}
+
+	// The record was found to have changed.
+	u.submitted = nil
+	m.observeRecord(u)
+	b.Reset()
+	m.write(&b)
+	if strings.Contains(b.String(), "dsddns_record_published_info{") {
+		t.Errorf("Metrics output still reports a published address")
+	}
+}
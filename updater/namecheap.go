@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Namecheap dynamic DNS protocol
+// see https://www.namecheap.com/support/knowledgebase/article.aspx/29/11/how-to-dynamically-update-the-hosts-ip-with-an-http-request/
+
+const (
+	namecheapCooldown = 15 * time.Minute
+	namecheapForever  = 10 * time.Hour * 24 * 365
+	namecheapEndpoint = "https://dynamicdns.park-your-domain.com/update"
+)
+
+// NamecheapService implements the Namecheap dynamic DNS protocol.
+type NamecheapService struct {
+	conf     *namecheapServiceConf
+	endpoint string
+}
+
+type namecheapServiceConf struct {
+	Host     string
+	Domain   string
+	Password string
+}
+
+type namecheapResponse struct {
+	ErrCount int
+	Errors   struct {
+		// The errors are numbered Err1, Err2, and so on.
+		Messages []string `xml:",any"`
+	} `xml:"errors"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *NamecheapService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	qs := url.Values{}
+	qs.Add("host", s.conf.Host)
+	qs.Add("domain", s.conf.Domain)
+	qs.Add("password", s.conf.Password)
+	qs.Add("ip", ip.String())
+	requrl := s.endpoint + "?" + qs.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requrl, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retryAfter = namecheapCooldown
+		err = errors.New("bad response code")
+		return
+	}
+
+	// Errors are reported in the body, with a successful status code.
+	var body namecheapResponse
+	decoder := xml.NewDecoder(resp.Body)
+	// The response claims to be UTF-16, but it is not.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err = decoder.Decode(&body); err != nil {
+		retryAfter = namecheapCooldown
+		return
+	}
+	if body.ErrCount > 0 {
+		return namecheapError(body.Errors.Messages)
+	}
+	return
+}
+
+func namecheapError(messages []string) (retryAfter time.Duration, err error) {
+	text := strings.Join(messages, "; ")
+	if text == "" {
+		text = "Unknown error."
+	}
+	if strings.Contains(strings.ToLower(text), "password") {
+		retryAfter = namecheapForever
+		text += ". Will not attempt further updates."
+	} else {
+		retryAfter = namecheapCooldown
+	}
+	err = errors.New(text)
+	return
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *NamecheapService) Identifier() string {
+	if s.conf.Host == "@" {
+		return s.conf.Domain
+	}
+	return s.conf.Host + "." + s.conf.Domain
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *NamecheapService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *NamecheapService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &namecheapServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.Host == "" {
+		s.conf.Host = "@"
+	}
+	s.endpoint = namecheapEndpoint
+	return nil
+}
@@ -0,0 +1,55 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNamecheapErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("password") != "secret" {
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-16"?>
+<interface-response>
+  <Command>SETDNSHOST</Command>
+  <Language>eng</Language>
+  <ErrCount>2</ErrCount>
+  <errors>
+    <Err1>Domain name not found</Err1>
+    <Err2>Passwords do not match</Err2>
+  </errors>
+  <ResponseCount>1</ResponseCount>
+  <Done>true</Done>
+</interface-response>`)
+			return
+		}
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-16"?>
+<interface-response>
+  <Command>SETDNSHOST</Command>
+  <Language>eng</Language>
+  <IP>192.0.2.1</IP>
+  <ErrCount>0</ErrCount>
+  <errors />
+  <ResponseCount>0</ResponseCount>
+  <Done>true</Done>
+</interface-response>`)
+	}))
+	defer server.Close()
+
+	s := &NamecheapService{
+		conf:     &namecheapServiceConf{Host: "host", Domain: "example.com", Password: "secret"},
+		endpoint: server.URL,
+	}
+	if _, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	s.conf.Password = "wrong"
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.1"))
+	if err == nil || retryAfter != namecheapForever {
+		t.Errorf("Submit = %s, %v; want a fatal error", retryAfter, err)
+	}
+}
@@ -0,0 +1,135 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Porkbun API v3
+// see https://porkbun.com/api/json/v3/documentation
+
+const (
+	porkbunCooldown   = 15 * time.Minute
+	porkbunThrottled  = time.Minute
+	porkbunForever    = 10 * time.Hour * 24 * 365
+	porkbunDefaultTTL = 600
+	porkbunEndpoint   = "https://api.porkbun.com/api/json/v3"
+)
+
+// PorkbunService implements the Porkbun API.
+type PorkbunService struct {
+	conf     *porkbunServiceConf
+	endpoint string
+}
+
+type porkbunServiceConf struct {
+	APIKey       string `yaml:"api_key"`
+	SecretAPIKey string `yaml:"secret_api_key"`
+	Domain       string
+	Name         string
+	TTL          int
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *PorkbunService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	path := "/dns/editByNameType/" + url.PathEscape(s.conf.Domain) + "/" + RecordTypeString(rtype)
+	if subdomain := relativeName(s.conf.Name, s.conf.Domain); subdomain != "@" {
+		path += "/" + url.PathEscape(subdomain)
+	}
+	body := struct {
+		APIKey       string `json:"apikey"`
+		SecretAPIKey string `json:"secretapikey"`
+		Content      string `json:"content"`
+		TTL          string `json:"ttl"`
+	}{
+		APIKey:       s.conf.APIKey,
+		SecretAPIKey: s.conf.SecretAPIKey,
+		Content:      ip.String(),
+		TTL:          strconv.Itoa(s.conf.TTL),
+	}
+	var resp struct {
+		Status  string
+		Message string
+	}
+	err = doJSON(ctx, "POST", s.endpoint+path, nil, body, &resp)
+
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusServiceUnavailable || httpErr.StatusCode == http.StatusTooManyRequests {
+			return porkbunThrottled, err
+		}
+		return porkbunError(httpErr.Message)
+	} else if err != nil {
+		return
+	}
+	if resp.Status != "SUCCESS" {
+		return porkbunError(resp.Message)
+	}
+	return
+}
+
+func porkbunError(message string) (retryAfter time.Duration, err error) {
+	const notAgain = "Will not attempt further updates."
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "api key"):
+		retryAfter = porkbunForever
+		message += " " + notAgain
+	case strings.Contains(lower, "opted in"):
+		retryAfter = porkbunForever
+		message += " Enable API access for the domain in the Porkbun dashboard. " + notAgain
+	case strings.Contains(lower, "rate limit") || strings.Contains(lower, "exceeded"):
+		retryAfter = porkbunThrottled
+	case message == "":
+		retryAfter = porkbunCooldown
+		message = "Unknown error."
+	default:
+		retryAfter = porkbunCooldown
+	}
+	err = errors.New(message)
+	return
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *PorkbunService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *PorkbunService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *PorkbunService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &porkbunServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.Domain == "" {
+		return errors.New("missing domain")
+	}
+	if s.conf.Name == "" {
+		return errors.New("missing name")
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = porkbunDefaultTTL
+	}
+	s.endpoint = porkbunEndpoint
+	return nil
+}
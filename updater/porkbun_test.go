@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPorkbunSubmit(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["apikey"] != "pk1_key" || body["secretapikey"] != "sk1_secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"status":"ERROR","message":"Invalid API key. (002)"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/dns/editByNameType/example.com/AAAA/host":
+			io.WriteString(w, `{"status":"SUCCESS"}`)
+		case "/dns/editByNameType/example.com/A":
+			io.WriteString(w, `{"status":"ERROR","message":"Domain is not opted in to API access."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := &PorkbunService{
+		conf:     &porkbunServiceConf{APIKey: "pk1_key", SecretAPIKey: "sk1_secret", Domain: "example.com", Name: "host.example.com", TTL: 600},
+		endpoint: server.URL,
+	}
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+		t.Fatal(err)
+	}
+	if body["content"] != "2001:db8::1" || body["ttl"] != "600" {
+		t.Errorf("Request body = %v", body)
+	}
+
+	s.conf.Name = "example.com"
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.1"))
+	if err == nil || retryAfter != porkbunForever {
+		t.Errorf("Submit = %s, %v; want a fatal error", retryAfter, err)
+	}
+
+	s.conf.SecretAPIKey = "wrong"
+	retryAfter, err = s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1"))
+	if err == nil || retryAfter != porkbunForever {
+		t.Errorf("Submit = %s, %v; want a fatal error", retryAfter, err)
+	}
+}
+
+func TestPorkbunRejectsConfig(t *testing.T) {
+	for _, data := range []string{
+		"api_key: pk1_key\nsecret_api_key: sk1_key\nname: host.example.com",
+		"api_key: pk1_key\nsecret_api_key: sk1_key\ndomain: example.com",
+	} {
+		var s PorkbunService
+		if err := yaml.Unmarshal([]byte(data), &s); err == nil {
+			t.Errorf("%q: want an error", data)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// PowerDNS Authoritative Server HTTP API
+// see https://doc.powerdns.com/authoritative/http-api/zone.html
+
+const (
+	powerDNSCooldown   = 15 * time.Minute
+	powerDNSDefaultTTL = 300
+	powerDNSServerID   = "localhost"
+)
+
+// PowerDNSService implements the PowerDNS Authoritative Server API. If the
+// zone is not configured, it is looked up by name the first time it is needed.
+//
+// PowerDNS replaces all of the values of a record set at once, so records that
+// share a name and type are published together.
+type PowerDNSService struct {
+	conf   *powerDNSServiceConf
+	zoneID string
+	set    *rrset
+}
+
+type powerDNSServiceConf struct {
+	URL      string
+	ServerID string `yaml:"server_id"`
+	APIKey   string `yaml:"api_key"`
+	Zone     string
+	Name     string
+	TTL      int
+}
+
+type powerDNSRRSet struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	TTL        int              `json:"ttl"`
+	ChangeType string           `json:"changetype"`
+	Records    []powerDNSRecord `json:"records"`
+}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *PowerDNSService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	if s.zoneID == "" {
+		if err = s.findZone(ctx); err != nil {
+			retryAfter = s.retryAfter(err)
+			return
+		}
+	}
+
+	change := powerDNSRRSet{
+		Name:       dns.Fqdn(s.conf.Name),
+		Type:       RecordTypeString(rtype),
+		TTL:        s.conf.TTL,
+		ChangeType: "REPLACE",
+	}
+	for _, value := range s.set.values(s, ip) {
+		change.Records = append(change.Records, powerDNSRecord{Content: value})
+	}
+	body := struct {
+		RRSets []powerDNSRRSet `json:"rrsets"`
+	}{[]powerDNSRRSet{change}}
+	err = s.do(ctx, "PATCH", "/zones/"+url.PathEscape(s.zoneID), body, nil)
+
+	// Forget the zone if it was looked up, in case it was recreated.
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		s.zoneID = s.configuredZone()
+	}
+	if err != nil {
+		retryAfter = s.retryAfter(err)
+	}
+	return
+}
+
+// findZone looks up the zone that contains the record.
+func (s *PowerDNSService) findZone(ctx context.Context) error {
+	for _, candidate := range zoneCandidates(s.conf.Name) {
+		qs := url.Values{}
+		qs.Add("zone", dns.Fqdn(candidate))
+		var zones []struct {
+			ID string
+		}
+		if err := s.do(ctx, "GET", "/zones?"+qs.Encode(), nil, &zones); err != nil {
+			return err
+		}
+		if len(zones) > 0 {
+			s.zoneID = zones[0].ID
+			return nil
+		}
+	}
+	return missingZoneError{s.conf.Name}
+}
+
+func (s *PowerDNSService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("X-API-Key", s.conf.APIKey)
+	endpoint := strings.TrimSuffix(s.conf.URL, "/") + "/api/v1/servers/" + url.PathEscape(s.conf.ServerID)
+	return doJSON(ctx, method, endpoint+path, header, in, out)
+}
+
+func (s *PowerDNSService) retryAfter(err error) time.Duration {
+	return apiRetryAfter(err, powerDNSCooldown, nil)
+}
+
+func (s *PowerDNSService) configuredZone() string {
+	if s.conf.Zone == "" {
+		return ""
+	}
+	return dns.Fqdn(s.conf.Zone)
+}
+
+func (s *PowerDNSService) rrsetKey() string {
+	return "powerdns " + s.conf.URL + " " + s.conf.ServerID + " " + strings.ToLower(dns.Fqdn(s.conf.Name))
+}
+
+func (s *PowerDNSService) joinRRSet(set *rrset) {
+	s.set = set
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *PowerDNSService) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *PowerDNSService) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *PowerDNSService) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &powerDNSServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.URL == "" {
+		return errors.New("missing url")
+	}
+	if s.conf.ServerID == "" {
+		s.conf.ServerID = powerDNSServerID
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = powerDNSDefaultTTL
+	}
+	s.zoneID = s.configuredZone()
+	return nil
+}
@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPowerDNSSubmit(t *testing.T) {
+	var patch struct {
+		RRSets []powerDNSRRSet
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, "Unauthorized")
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v1/servers/localhost/zones" && r.URL.Query().Get("zone") == "example.com.":
+			io.WriteString(w, `[{"id":"example.com.","name":"example.com.","kind":"Native"}]`)
+		case r.URL.Path == "/api/v1/servers/localhost/zones":
+			io.WriteString(w, `[]`)
+		case r.URL.Path == "/api/v1/servers/localhost/zones/example.com." && r.Method == "PATCH":
+			json.NewDecoder(r.Body).Decode(&patch)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	s := &PowerDNSService{
+		conf: &powerDNSServiceConf{URL: server.URL + "/", ServerID: "localhost", APIKey: "secret", Name: "host.lab.example.com", TTL: 60},
+	}
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+		t.Fatal(err)
+	}
+	want := []powerDNSRRSet{{
+		Name:       "host.lab.example.com.",
+		Type:       "AAAA",
+		TTL:        60,
+		ChangeType: "REPLACE",
+		Records:    []powerDNSRecord{{Content: "2001:db8::1"}},
+	}}
+	if !reflect.DeepEqual(patch.RRSets, want) {
+		t.Errorf("Record sets = %+v; want %+v", patch.RRSets, want)
+	}
+
+	s.conf.APIKey = "wrong"
+	if retryAfter, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err == nil || retryAfter != powerDNSCooldown {
+		t.Errorf("Submit = %s, %v; want an error", retryAfter, err)
+	}
+}
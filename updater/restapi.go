@@ -0,0 +1,192 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpError reports an unsuccessful response from a web API.
+type httpError struct {
+	StatusCode int
+	Header     http.Header
+	Message    string
+}
+
+func (e *httpError) Error() string {
+	text := strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	return text
+}
+
+// doJSON sends a request to a JSON web API. If in is not nil, it is encoded as
+// the request body; if out is not nil, the response body is decoded into it.
+// Unsuccessful responses are returned as an *httpError.
+func doJSON(ctx context.Context, method, url string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &httpError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Message:    jsonErrorMessage(data),
+		}
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// jsonErrorMessage extracts a human-readable message from an error response,
+// trying the conventions used by the most common APIs.
+func jsonErrorMessage(data []byte) string {
+	var body struct {
+		Message string
+		Detail  string
+		Error   json.RawMessage
+		Errors  []struct {
+			Reason  string
+			Message string
+		}
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return strings.TrimSpace(string(data))
+	}
+	switch {
+	case body.Message != "":
+		return body.Message
+	case body.Detail != "":
+		return body.Detail
+	case len(body.Errors) > 0 && body.Errors[0].Reason != "":
+		return body.Errors[0].Reason
+	case len(body.Errors) > 0:
+		return body.Errors[0].Message
+	case len(body.Error) > 0:
+		var text string
+		if json.Unmarshal(body.Error, &text) == nil {
+			return text
+		}
+		var nested struct{ Message string }
+		if json.Unmarshal(body.Error, &nested) == nil {
+			return nested.Message
+		}
+	}
+	return ""
+}
+
+// parseRetryAfter interprets a Retry-After header, which gives either a number
+// of seconds or a date. It returns zero if the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// A recordAPI is a web API that updates records by their identifiers, which
+// are looked up by name and remembered.
+type recordAPI interface {
+	// findRecord looks up the identifiers of the record, if necessary. It
+	// returns a missingRecordError if the record does not exist.
+	findRecord(ctx context.Context, rtype RecordType) error
+
+	// createRecord creates the record and remembers its identifier.
+	createRecord(ctx context.Context, rtype RecordType, ip net.IP) error
+
+	// updateRecord changes the value of the record that was found.
+	updateRecord(ctx context.Context, rtype RecordType, ip net.IP) error
+
+	// forgetRecord discards the identifiers that were looked up.
+	forgetRecord()
+
+	// retryAfter determines when to try again after an error.
+	retryAfter(err error) time.Duration
+}
+
+// submitRecord sends the provided IP address to a record API, creating the
+// record if it is missing and create is set. In case of failure, it returns a
+// retry delay and the error.
+func submitRecord(ctx context.Context, api recordAPI, rtype RecordType, ip net.IP, create bool) (retryAfter time.Duration, err error) {
+	err = api.findRecord(ctx, rtype)
+	var missing missingRecordError
+	if errors.As(err, &missing) && create {
+		err = api.createRecord(ctx, rtype, ip)
+	} else if err == nil {
+		err = api.updateRecord(ctx, rtype, ip)
+	}
+
+	// Forget identifiers that were looked up, in case the record was deleted.
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		api.forgetRecord()
+	}
+	if err != nil {
+		retryAfter = api.retryAfter(err)
+	}
+	return
+}
+
+// apiRetryAfter determines when to try again after an error from a web API.
+// Rate limited requests can be retried after the delay that rateLimit reads
+// from the response headers, if it is not nil. Other errors from the API, and
+// missing zones and records, wait for the cooldown.
+func apiRetryAfter(err error, cooldown time.Duration, rateLimit func(http.Header) time.Duration) time.Duration {
+	var httpErr *httpError
+	if !errors.As(err, &httpErr) {
+		var missingZone missingZoneError
+		var missingRecord missingRecordError
+		if errors.As(err, &missingZone) || errors.As(err, &missingRecord) {
+			return cooldown
+		}
+		return 0
+	}
+	if httpErr.StatusCode == http.StatusTooManyRequests && rateLimit != nil {
+		if delay := rateLimit(httpErr.Header); delay > 0 {
+			return delay
+		}
+	}
+	return cooldown
+}
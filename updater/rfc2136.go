@@ -0,0 +1,129 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// RFC 2136 dynamic updates, authenticated with RFC 8945 TSIG
+// see https://www.rfc-editor.org/rfc/rfc2136
+
+const (
+	rfc2136Cooldown   = 15 * time.Minute
+	rfc2136DefaultTTL = 300
+	rfc2136Fudge      = 300
+)
+
+// RFC2136Service sends DNS UPDATE messages directly to an authoritative server.
+type RFC2136Service struct {
+	conf *rfc2136ServiceConf
+}
+
+type rfc2136ServiceConf struct {
+	Server       string
+	Zone         string
+	Name         string
+	TTL          int
+	KeyName      string `yaml:"key_name"`
+	KeyAlgorithm string `yaml:"key_algorithm"`
+	KeySecret    string `yaml:"key_secret"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *RFC2136Service) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	var rr dns.RR
+	hdr := dns.RR_Header{
+		Name:  dns.Fqdn(s.conf.Name),
+		Class: dns.ClassINET,
+		Ttl:   uint32(s.conf.TTL),
+	}
+	switch rtype {
+	case ARecord:
+		hdr.Rrtype = dns.TypeA
+		rr = &dns.A{Hdr: hdr, A: ip}
+	case AAAARecord:
+		hdr.Rrtype = dns.TypeAAAA
+		rr = &dns.AAAA{Hdr: hdr, AAAA: ip}
+	default:
+		err = errors.New("unsupported record type")
+		return
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(s.conf.Zone))
+	msg.RemoveRRset([]dns.RR{rr})
+	msg.Insert([]dns.RR{rr})
+
+	client := &dns.Client{}
+	if s.conf.KeyName != "" {
+		keyName := dns.Fqdn(strings.ToLower(s.conf.KeyName))
+		client.TsigSecret = map[string]string{keyName: s.conf.KeySecret}
+		msg.SetTsig(keyName, s.conf.KeyAlgorithm, rfc2136Fudge, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, s.conf.Server)
+	if err != nil {
+		if errors.Is(err, dns.ErrSig) || errors.Is(err, dns.ErrSecret) || errors.Is(err, dns.ErrKeyAlg) {
+			retryAfter = rfc2136Cooldown
+		}
+		return
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		retryAfter = rfc2136Cooldown
+		err = errors.New("server responded with " + dns.RcodeToString[resp.Rcode])
+		return
+	}
+	return
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *RFC2136Service) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *RFC2136Service) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *RFC2136Service) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &rfc2136ServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.Server == "" {
+		return errors.New("missing server")
+	}
+	if _, _, err := net.SplitHostPort(s.conf.Server); err != nil {
+		s.conf.Server = net.JoinHostPort(s.conf.Server, "53")
+	}
+	if s.conf.Zone == "" {
+		return errors.New("missing zone")
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = rfc2136DefaultTTL
+	}
+	switch strings.ToLower(s.conf.KeyAlgorithm) {
+	case "", "hmac-sha256":
+		s.conf.KeyAlgorithm = dns.HmacSHA256
+	case "hmac-sha512":
+		s.conf.KeyAlgorithm = dns.HmacSHA512
+	default:
+		return errors.New("unsupported TSIG algorithm")
+	}
+	return nil
+}
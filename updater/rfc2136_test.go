@@ -0,0 +1,104 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+const testTsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+
+func startDNSServer(t *testing.T, handler dns.HandlerFunc) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           handler,
+		TsigSecret:        map[string]string{"dsddns.": testTsigSecret},
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+	}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	<-started
+	return pc.LocalAddr().String()
+}
+
+func TestRFC2136Submit(t *testing.T) {
+	updates := make(chan *dns.Msg, 1)
+	addr := startDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if tsig := req.IsTsig(); tsig == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeNotAuth
+		} else {
+			updates <- req
+			resp.SetTsig("dsddns.", dns.HmacSHA256, rfc2136Fudge, int64(tsig.TimeSigned))
+		}
+		w.WriteMsg(resp)
+	})
+
+	var s RFC2136Service
+	data := []byte(`
+server: ` + addr + `
+zone: example.com
+name: host.example.com
+key_name: dsddns
+key_secret: ` + testTsigSecret)
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+		t.Fatal(err)
+	}
+	var got *dns.Msg
+	select {
+	case got = <-updates:
+	default:
+		t.Fatal("Server did not accept the update")
+	}
+	if got.Question[0].Name != "example.com." {
+		t.Errorf("Zone = %s; want example.com.", got.Question[0].Name)
+	}
+	if len(got.Ns) != 2 {
+		t.Fatalf("Number of update RRs = %d; want 2", len(got.Ns))
+	}
+	if got.Ns[0].Header().Class != dns.ClassANY || got.Ns[0].Header().Rrtype != dns.TypeAAAA {
+		t.Errorf("First update RR = %s; want RRset deletion", got.Ns[0].String())
+	}
+	if aaaa, ok := got.Ns[1].(*dns.AAAA); !ok || !aaaa.AAAA.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Second update RR = %s; want AAAA 2001:db8::1", got.Ns[1].String())
+	}
+}
+
+func TestRFC2136SubmitRefused(t *testing.T) {
+	addr := startDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(resp)
+	})
+
+	var s RFC2136Service
+	data := []byte(`
+server: ` + addr + `
+zone: example.com
+name: host.example.com`)
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	retryAfter, err := s.Submit(context.Background(), ARecord, net.ParseIP("192.0.2.1").To4())
+	if err == nil {
+		t.Fatal("Submit should fail when the server refuses the update")
+	}
+	if retryAfter != rfc2136Cooldown {
+		t.Errorf("Retry delay = %s; want %s", retryAfter, rfc2136Cooldown)
+	}
+}
@@ -0,0 +1,230 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Amazon Route 53 API
+// see https://docs.aws.amazon.com/Route53/latest/APIReference/API_ChangeResourceRecordSets.html
+
+const (
+	route53Cooldown   = 15 * time.Minute
+	route53Throttled  = time.Minute
+	route53DefaultTTL = 300
+	route53Endpoint   = "https://route53.amazonaws.com"
+	route53Region     = "us-east-1"
+	route53Namespace  = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+// Route53Service implements the Amazon Route 53 API. If the hosted zone is not
+// configured, it is looked up by name the first time it is needed.
+type Route53Service struct {
+	conf     *route53ServiceConf
+	endpoint string
+	zoneID   string
+}
+
+type route53ServiceConf struct {
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	CredentialsFile string `yaml:"credentials_file"`
+	Profile         string
+	HostedZoneID    string `yaml:"hosted_zone_id"`
+	Name            string
+	TTL             int
+}
+
+type route53ChangeRequest struct {
+	XMLName     xml.Name `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	ChangeBatch struct {
+		Changes []route53Change `xml:"Changes>Change"`
+	}
+}
+
+type route53Change struct {
+	Action            string
+	ResourceRecordSet struct {
+		Name            string
+		Type            string
+		TTL             int
+		ResourceRecords []string `xml:"ResourceRecords>ResourceRecord>Value"`
+	}
+}
+
+type route53HostedZones struct {
+	HostedZones []struct {
+		ID     string `xml:"Id"`
+		Name   string
+		Config struct {
+			PrivateZone bool
+		}
+	} `xml:"HostedZones>HostedZone"`
+}
+
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Submit sends the provided IP address to the dynamic DNS service. In case of failure, it returns a retry delay and the error.
+func (s *Route53Service) Submit(ctx context.Context, rtype RecordType, ip net.IP) (retryAfter time.Duration, err error) {
+	creds, err := s.credentials()
+	if err != nil {
+		retryAfter = route53Cooldown
+		return
+	}
+	if s.zoneID == "" {
+		if retryAfter, err = s.findZone(ctx, creds); err != nil {
+			return
+		}
+	}
+
+	var change route53Change
+	change.Action = "UPSERT"
+	change.ResourceRecordSet.Name = s.conf.Name
+	change.ResourceRecordSet.Type = RecordTypeString(rtype)
+	change.ResourceRecordSet.TTL = s.conf.TTL
+	change.ResourceRecordSet.ResourceRecords = []string{ip.String()}
+	var body route53ChangeRequest
+	body.Xmlns = route53Namespace
+	body.ChangeBatch.Changes = []route53Change{change}
+
+	retryAfter, err = s.do(ctx, creds, "POST", "/2013-04-01/hostedzone/"+s.zoneID+"/rrset/", body, nil)
+	var apiErr route53Error
+	if errors.As(err, &apiErr) && apiErr.Code == "NoSuchHostedZone" {
+		s.zoneID = strings.TrimPrefix(s.conf.HostedZoneID, "/hostedzone/")
+	}
+	return
+}
+
+// findZone looks up the public hosted zone that contains the record.
+func (s *Route53Service) findZone(ctx context.Context, creds awsCredentials) (retryAfter time.Duration, err error) {
+	for _, candidate := range zoneCandidates(s.conf.Name) {
+		qs := url.Values{}
+		qs.Add("dnsname", candidate)
+		qs.Add("maxitems", "10")
+		var zones route53HostedZones
+		retryAfter, err = s.do(ctx, creds, "GET", "/2013-04-01/hostedzonesbyname?"+qs.Encode(), nil, &zones)
+		if err != nil {
+			return
+		}
+		for _, zone := range zones.HostedZones {
+			if strings.TrimSuffix(zone.Name, ".") == candidate && !zone.Config.PrivateZone {
+				s.zoneID = strings.TrimPrefix(zone.ID, "/hostedzone/")
+				return
+			}
+		}
+	}
+	return route53Cooldown, errors.New("cannot find a hosted zone for " + s.conf.Name)
+}
+
+// do sends a signed request to the Route 53 API.
+func (s *Route53Service) do(ctx context.Context, creds awsCredentials, method, path string, in interface{}, out interface{}) (retryAfter time.Duration, err error) {
+	var body []byte
+	if in != nil {
+		body, err = xml.Marshal(in)
+		if err != nil {
+			return
+		}
+		body = append([]byte(xml.Header), body...)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+	signV4(req, body, creds, route53Region, "route53", time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr route53Error
+		if xml.Unmarshal(data, &apiErr) != nil || apiErr.Code == "" {
+			apiErr.Code = strconv.Itoa(resp.StatusCode)
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		switch apiErr.Code {
+		case "Throttling", "PriorRequestNotComplete":
+			retryAfter = route53Throttled
+		default:
+			retryAfter = route53Cooldown
+		}
+		err = apiErr
+		return
+	}
+	if out != nil {
+		err = xml.Unmarshal(data, out)
+	}
+	return
+}
+
+func (e route53Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (s *Route53Service) credentials() (awsCredentials, error) {
+	if s.conf.AccessKeyID != "" {
+		return awsCredentials{
+			AccessKeyID:     s.conf.AccessKeyID,
+			SecretAccessKey: s.conf.SecretAccessKey,
+			SessionToken:    s.conf.SessionToken,
+		}, nil
+	}
+	// Read the file every time, in case the credentials were rotated.
+	return loadSharedCredentials(s.conf.CredentialsFile, s.conf.Profile)
+}
+
+// Identifier returns a human readable name for this service given its endpoint.
+func (s *Route53Service) Identifier() string {
+	return s.conf.Name
+}
+
+// SupportsRecord determines whether this service supports the provided DNS record type.
+func (s *Route53Service) SupportsRecord(rtype RecordType) bool {
+	switch rtype {
+	case ARecord:
+		return true
+	case AAAARecord:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML constructs a service from a YAML configuration.
+func (s *Route53Service) UnmarshalYAML(value *yaml.Node) error {
+	s.conf = &route53ServiceConf{}
+	if err := value.Decode(s.conf); err != nil {
+		return err
+	}
+	if s.conf.TTL <= 0 {
+		s.conf.TTL = route53DefaultTTL
+	}
+	s.endpoint = route53Endpoint
+	s.zoneID = strings.TrimPrefix(s.conf.HostedZoneID, "/hostedzone/")
+	return nil
+}
@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoute53Submit(t *testing.T) {
+	var got route53ChangeRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2013-04-01/hostedzonesbyname", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `<?xml version="1.0"?>
+<ListHostedZonesByNameResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <HostedZones>
+    <HostedZone><Id>/hostedzone/ZPRIVATE</Id><Name>example.com.</Name><Config><PrivateZone>true</PrivateZone></Config></HostedZone>
+    <HostedZone><Id>/hostedzone/ZPUBLIC</Id><Name>example.com.</Name><Config><PrivateZone>false</PrivateZone></Config></HostedZone>
+  </HostedZones>
+</ListHostedZonesByNameResponse>`)
+	})
+	mux.HandleFunc("/2013-04-01/hostedzone/ZPUBLIC/rrset/", func(w http.ResponseWriter, r *http.Request) {
+		if err := xml.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		io.WriteString(w, `<?xml version="1.0"?><ChangeResourceRecordSetsResponse/>`)
+	})
+	mux.HandleFunc("/2013-04-01/hostedzone/ZMISSING/rrset/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `<?xml version="1.0"?>
+<ErrorResponse><Error><Type>Sender</Type><Code>NoSuchHostedZone</Code><Message>No hosted zone found</Message></Error></ErrorResponse>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	credentials := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credentials, []byte(`
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = secret
+
+[dsddns]
+aws_access_key_id = AKIDEXAMPLE
+aws_secret_access_key = wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Route53Service{
+		conf: &route53ServiceConf{
+			CredentialsFile: credentials,
+			Profile:         "dsddns",
+			Name:            "host.example.com",
+			TTL:             60,
+		},
+		endpoint: server.URL,
+	}
+	if _, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ChangeBatch.Changes) != 1 {
+		t.Fatalf("Number of changes = %d; want 1", len(got.ChangeBatch.Changes))
+	}
+	change := got.ChangeBatch.Changes[0]
+	rrset := change.ResourceRecordSet
+	if change.Action != "UPSERT" || rrset.Name != "host.example.com" || rrset.Type != "AAAA" || rrset.TTL != 60 {
+		t.Errorf("Change = %+v; want UPSERT of host.example.com AAAA with TTL 60", change)
+	}
+	if len(rrset.ResourceRecords) != 1 || rrset.ResourceRecords[0] != "2001:db8::1" {
+		t.Errorf("Record values = %v; want 2001:db8::1", rrset.ResourceRecords)
+	}
+
+	s.zoneID = "ZMISSING"
+	retryAfter, err := s.Submit(context.Background(), AAAARecord, net.ParseIP("2001:db8::1"))
+	if err == nil || !strings.Contains(err.Error(), "NoSuchHostedZone") {
+		t.Errorf("Error = %v; want NoSuchHostedZone", err)
+	}
+	if retryAfter != route53Cooldown {
+		t.Errorf("Retry delay = %s; want %s", retryAfter, route53Cooldown)
+	}
+	if s.zoneID != "" {
+		t.Errorf("Hosted zone = %s; want it to be looked up again", s.zoneID)
+	}
+}
@@ -0,0 +1,437 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UPnP Internet Gateway Device, NAT Port Mapping Protocol, and Port Control
+// Protocol
+// see http://upnp.org/specs/gw/UPnP-gw-WANIPConnection-v2-Service.pdf,
+// https://www.rfc-editor.org/rfc/rfc6886, and
+// https://www.rfc-editor.org/rfc/rfc6887
+
+const (
+	ssdpAddress    = "239.255.255.250:1900"
+	ssdpTimeout    = 2 * time.Second
+	routerTimeout  = 5 * time.Second
+	natPMPPort     = 5351
+	natPMPInitial  = 250 * time.Millisecond
+	natPMPAttempts = 3
+	pcpLifetime    = 30
+	pcpProtocolUDP = 17
+
+	// Routers multicast announcements to this port on 224.0.0.1.
+	natPMPClientPort = 5350
+)
+
+var ssdpSearchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+// igdServiceTypes lists the services that can report the WAN address, in order
+// of preference.
+var igdServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+type igdControl struct {
+	url         string
+	serviceType string
+}
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// RouterIP asks the router for its WAN address. UPnP is tried first, then
+// NAT-PMP and PCP on the default gateway. Only IPv4 addresses are supported.
+func (l IPLookup) RouterIP(ctx context.Context, intname string) net.IP {
+	start := time.Now()
+	ip, err := l.upnpIP(ctx, intname)
+	metrics.observeLookup("upnp", time.Since(start), err)
+	if err == nil {
+		return ip
+	}
+
+	gateway, err := defaultGateway(intname)
+	if err != nil {
+		return nil
+	}
+	server := net.JoinHostPort(gateway.String(), strconv.Itoa(natPMPPort))
+	start = time.Now()
+	ip, err = natPMPIP(ctx, server)
+	metrics.observeLookup("natpmp", time.Since(start), err)
+	if err == nil {
+		return ip
+	}
+	start = time.Now()
+	ip, err = pcpIP(ctx, server)
+	metrics.observeLookup("pcp", time.Since(start), err)
+	if err == nil {
+		return ip
+	}
+	return nil
+}
+
+// upnpIP queries the Internet Gateway Device on the local network. Its control
+// URL is remembered, so that it is only discovered once.
+func (l IPLookup) upnpIP(ctx context.Context, intname string) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, routerTimeout)
+	defer cancel()
+
+	control, ok := l.routers[intname]
+	if !ok {
+		location, err := ssdpSearch(ctx, intname)
+		if err != nil {
+			return nil, err
+		}
+		if control, err = igdControlURL(ctx, location); err != nil {
+			return nil, err
+		}
+		l.routers[intname] = control
+	}
+
+	ip, err := igdExternalIP(ctx, control)
+	if err != nil {
+		// The router may have restarted with a different control URL.
+		delete(l.routers, intname)
+		return nil, err
+	}
+	return ip, nil
+}
+
+// ssdpSearch multicasts a search for Internet Gateway Devices and returns the
+// location of the first device description it receives.
+func ssdpSearch(ctx context.Context, intname string) (string, error) {
+	laddr := &net.UDPAddr{}
+	if intf, _ := net.InterfaceByName(intname); intf != nil {
+		if addrs := sourceAddresses(ARecord, intf); len(addrs) > 0 {
+			laddr.IP = addrs[0]
+		}
+	}
+	conn, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return "", err
+	}
+
+	for _, target := range ssdpSearchTargets {
+		request := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddress + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 1\r\n" +
+			"ST: " + target + "\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(request), group); err != nil {
+			return "", err
+		}
+	}
+
+	deadline := time.Now().Add(ssdpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", errors.New("no UPnP gateway found")
+		}
+		if location := ssdpLocation(buf[:n]); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// ssdpLocation extracts the LOCATION header from a search response.
+func ssdpLocation(data []byte) string {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Location")
+}
+
+// igdControlURL reads a device description and returns the control URL of its
+// WAN connection service.
+func igdControlURL(ctx context.Context, location string) (control igdControl, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New("bad response code")
+		return
+	}
+
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return
+	}
+	if root.URLBase != "" {
+		if base, err = url.Parse(root.URLBase); err != nil {
+			return
+		}
+	}
+
+	for _, serviceType := range igdServiceTypes {
+		if path := findUPnPService(root.Device, serviceType); path != "" {
+			var ref *url.URL
+			if ref, err = url.Parse(path); err != nil {
+				return
+			}
+			control.url = base.ResolveReference(ref).String()
+			control.serviceType = serviceType
+			return
+		}
+	}
+	err = errors.New("UPnP gateway has no WAN connection service")
+	return
+}
+
+func findUPnPService(device upnpDevice, serviceType string) string {
+	for _, service := range device.Services {
+		if service.ServiceType == serviceType {
+			return service.ControlURL
+		}
+	}
+	for _, child := range device.Devices {
+		if path := findUPnPService(child, serviceType); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// igdExternalIP calls the GetExternalIPAddress action.
+func igdExternalIP(ctx context.Context, control igdControl) (net.IP, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + control.serviceType + `"></u:GetExternalIPAddress></s:Body>` +
+		`</s:Envelope>`
+	req, err := http.NewRequestWithContext(ctx, "POST", control.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+control.serviceType+`#GetExternalIPAddress"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("bad response code")
+	}
+
+	var envelope struct {
+		Address string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, err
+	}
+	return publicRouterIP(net.ParseIP(strings.TrimSpace(envelope.Address)))
+}
+
+// natPMPIP asks a NAT-PMP server for its external address.
+func natPMPIP(ctx context.Context, server string) (net.IP, error) {
+	conn, err := net.Dial("udp4", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := udpExchange(ctx, conn, []byte{0, 0}, func(resp []byte) bool {
+		return len(resp) >= 12 && resp[0] == 0 && resp[1] == 128
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result := binary.BigEndian.Uint16(resp[2:]); result != 0 {
+		return nil, errors.New("NAT-PMP server responded with result code " + strconv.Itoa(int(result)))
+	}
+	return publicRouterIP(net.IP(resp[8:12]).To16())
+}
+
+// pcpIP asks a PCP server for its external address. PCP has no request for
+// just the address, so a short-lived mapping for our own UDP port is created
+// and then deleted.
+func pcpIP(ctx context.Context, server string) (net.IP, error) {
+	conn, err := net.Dial("udp4", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.UDPAddr)
+
+	var nonce [12]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	request := func(lifetime uint32) []byte {
+		msg := make([]byte, 60)
+		msg[0] = 2 // version
+		msg[1] = 1 // MAP
+		binary.BigEndian.PutUint32(msg[4:], lifetime)
+		copy(msg[8:24], local.IP.To16())
+		copy(msg[24:36], nonce[:])
+		msg[36] = pcpProtocolUDP
+		binary.BigEndian.PutUint16(msg[40:], uint16(local.Port))
+		copy(msg[44:60], net.IPv4zero.To16())
+		return msg
+	}
+	accept := func(resp []byte) bool {
+		return len(resp) >= 60 && resp[0] == 2 && resp[1] == 0x81 && bytes.Equal(resp[24:36], nonce[:])
+	}
+
+	resp, err := udpExchange(ctx, conn, request(pcpLifetime), accept)
+	if err != nil {
+		return nil, err
+	}
+	if result := resp[3]; result != 0 {
+		return nil, errors.New("PCP server responded with result code " + strconv.Itoa(int(result)))
+	}
+	ip := net.IP(append([]byte(nil), resp[44:60]...))
+	udpExchange(ctx, conn, request(0), accept)
+	return publicRouterIP(ip)
+}
+
+// udpExchange sends a request, retransmitting it with exponential backoff,
+// until a response is accepted.
+func udpExchange(ctx context.Context, conn net.Conn, request []byte, accept func([]byte) bool) ([]byte, error) {
+	buf := make([]byte, 1100)
+	wait := natPMPInitial
+	for attempt := 0; attempt < natPMPAttempts; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(wait)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if accept(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		wait *= 2
+	}
+	return nil, errors.New("no response from " + conn.RemoteAddr().String())
+}
+
+// WatchRouter listens for the announcements that NAT-PMP and PCP routers
+// multicast to the local network when their WAN address changes or they
+// restart. The channel receives a value for each announcement, and it is closed
+// when the context is done. The announcements are not trusted; they only
+// prompt a new query to the router.
+func WatchRouter(ctx context.Context, logger *log.Logger) (<-chan struct{}, error) {
+	group := &net.UDPAddr{IP: net.IPv4allsys, Port: natPMPClientPort}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer close(announcements)
+		buf := make([]byte, 1100)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Println("stopped watching for router announcements:", err)
+				}
+				return
+			}
+			if !isRouterAnnouncement(buf[:n]) {
+				continue
+			}
+			select {
+			case announcements <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return announcements, nil
+}
+
+// isRouterAnnouncement recognizes a NAT-PMP external address response or a PCP
+// ANNOUNCE response.
+func isRouterAnnouncement(msg []byte) bool {
+	switch {
+	case len(msg) >= 12 && msg[0] == 0 && msg[1] == 128:
+		return true
+	case len(msg) >= 24 && msg[0] == 2 && msg[1] == 0x80:
+		return true
+	default:
+		return false
+	}
+}
+
+// publicRouterIP checks that a router's WAN address can be published. Behind
+// carrier-grade or double NAT, the router only knows a private address.
+func publicRouterIP(ip net.IP) (net.IP, error) {
+	if ip == nil || !isIPv4(ip) || !ip.IsGlobalUnicast() {
+		return nil, errors.New("router has no WAN address")
+	}
+	for _, cidr := range []string{"10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16"} {
+		_, private, _ := net.ParseCIDR(cidr)
+		if private.Contains(ip) {
+			return nil, errors.New("router's WAN address " + ip.String() + " is not public")
+		}
+	}
+	return ip, nil
+}
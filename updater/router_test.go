@@ -0,0 +1,192 @@
+package updater
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUPnPExternalIP(t *testing.T) {
+	var action string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rootDesc.xml":
+			io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`)
+		case "/ctl/IPConn":
+			action = r.Header.Get("SOAPAction")
+			io.WriteString(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+      <NewExternalIPAddress>198.51.100.7</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	location := ssdpLocation([]byte("HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=120\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"LOCATION: " + server.URL + "/rootDesc.xml\r\n\r\n"))
+	if location != server.URL+"/rootDesc.xml" {
+		t.Fatalf("Location = %q", location)
+	}
+	control, err := igdControlURL(context.Background(), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if control.url != server.URL+"/ctl/IPConn" {
+		t.Errorf("Control URL = %q", control.url)
+	}
+	ip, err := igdExternalIP(context.Background(), control)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("198.51.100.7")) {
+		t.Errorf("Address = %s; want 198.51.100.7", ip)
+	}
+	if action != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` {
+		t.Errorf("SOAPAction = %s", action)
+	}
+}
+
+// startUDPResponder answers each request with the result of respond, if it is
+// not nil.
+func startUDPResponder(t *testing.T, respond func(req []byte) []byte) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := respond(buf[:n]); resp != nil {
+				conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNATPMPExternalIP(t *testing.T) {
+	server := startUDPResponder(t, func(req []byte) []byte {
+		if len(req) != 2 || req[0] != 0 || req[1] != 0 {
+			return nil
+		}
+		return []byte{0, 128, 0, 0, 0, 0, 0, 42, 198, 51, 100, 7}
+	})
+	ip, err := natPMPIP(context.Background(), server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("198.51.100.7")) {
+		t.Errorf("Address = %s; want 198.51.100.7", ip)
+	}
+}
+
+func TestPCPExternalIP(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		lifetimes []uint32
+	)
+	server := startUDPResponder(t, func(req []byte) []byte {
+		if len(req) != 60 || req[0] != 2 || req[1] != 1 {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		lifetimes = append(lifetimes, binary.BigEndian.Uint32(req[4:]))
+		resp := make([]byte, 60)
+		copy(resp, req)
+		resp[1] = 0x81
+		resp[3] = 0
+		copy(resp[44:], net.ParseIP("198.51.100.7").To16())
+		return resp
+	})
+	ip, err := pcpIP(context.Background(), server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("198.51.100.7")) {
+		t.Errorf("Address = %s; want 198.51.100.7", ip)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lifetimes) != 2 || lifetimes[0] != pcpLifetime || lifetimes[1] != 0 {
+		t.Errorf("Lifetimes = %v; want the mapping to be created and deleted", lifetimes)
+	}
+}
+
+func TestPublicRouterIP(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"198.51.100.7": true,
+		"100.64.1.1":   false,
+		"192.168.1.1":  false,
+		"0.0.0.0":      false,
+		"2001:db8::1":  false,
+	} {
+		_, err := publicRouterIP(net.ParseIP(addr))
+		if (err == nil) != public {
+			t.Errorf("publicRouterIP(%s) = %v; want public = %t", addr, err, public)
+		}
+	}
+	if _, err := publicRouterIP(nil); err == nil || !strings.Contains(err.Error(), "no WAN address") {
+		t.Errorf("publicRouterIP(nil) = %v", err)
+	}
+}
+
+func TestIsRouterAnnouncement(t *testing.T) {
+	natPMP := []byte{0, 128, 0, 0, 0, 0, 0, 9, 203, 0, 113, 1}
+	pcp := make([]byte, 24)
+	pcp[0], pcp[1] = 2, 0x80
+	for _, test := range []struct {
+		msg  []byte
+		want bool
+	}{
+		{natPMP, true},
+		{pcp, true},
+		{natPMP[:8], false},
+		{[]byte{0, 0}, false},
+		{pcp[:12], false},
+	} {
+		if got := isRouterAnnouncement(test.msg); got != test.want {
+			t.Errorf("isRouterAnnouncement(%v) = %t; want %t", test.msg, got, test.want)
+		}
+	}
+}
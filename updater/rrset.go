@@ -0,0 +1,56 @@
+package updater
+
+import "net"
+
+// rrsetService is implemented by services that replace all of the values for a
+// name and type at once. Records that publish to the same record set are
+// linked together, so that each submission includes the addresses of the
+// others.
+type rrsetService interface {
+	RecordService
+	rrsetKey() string
+	joinRRSet(set *rrset)
+}
+
+// rrset links the records that share a record set.
+type rrset struct {
+	members []*Updater
+}
+
+// values returns the addresses to publish when the member with the provided
+// service submits ip. Members that have not submitted anything yet are left
+// out.
+func (set *rrset) values(self RecordService, ip net.IP) []string {
+	ips := []net.IP{ip}
+	if set != nil {
+		for _, member := range set.members {
+			if member.Service != self && member.submitted != nil && !containsIP(ips, member.submitted) {
+				ips = append(ips, member.submitted)
+			}
+		}
+	}
+	values := make([]string, len(ips))
+	for i, ip := range ips {
+		values[i] = ip.String()
+	}
+	return values
+}
+
+// linkRRSets groups the records that share a record set.
+func linkRRSets(updaters Updaters) {
+	sets := make(map[string]*rrset)
+	for _, updater := range updaters {
+		service, ok := updater.Service.(rrsetService)
+		if !ok {
+			continue
+		}
+		key := RecordTypeString(updater.Type) + " " + service.rrsetKey()
+		set, ok := sets[key]
+		if !ok {
+			set = &rrset{}
+			sets[key] = set
+		}
+		set.members = append(set.members, updater)
+		service.joinRRSet(set)
+	}
+}
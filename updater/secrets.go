@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeys lists the configuration keys whose values can instead be read
+// from a file, by appending "_file" to the key.
+var secretKeys = map[string]bool{
+	"api_key":           true,
+	"api_token":         true,
+	"key_secret":        true,
+	"password":          true,
+	"secret_access_key": true,
+	"secret_api_key":    true,
+	"session_token":     true,
+	"token":             true,
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveSecrets returns a copy of a YAML configuration with ${VAR} references
+// replaced by the values of environment variables, and secrets given as
+// *_file keys replaced by the contents of those files. Aliases are resolved
+// along the way.
+func resolveSecrets(node *yaml.Node) (*yaml.Node, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return resolveSecrets(node.Alias)
+	case yaml.ScalarNode:
+		return expandScalar(node)
+	}
+
+	copied := *node
+	copied.Anchor = ""
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		resolved, err := resolveSecrets(child)
+		if err != nil {
+			return nil, err
+		}
+		copied.Content[i] = resolved
+	}
+
+	if copied.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(copied.Content); i += 2 {
+			key := copied.Content[i]
+			name := strings.TrimSuffix(key.Value, "_file")
+			if name == key.Value || !secretKeys[name] {
+				continue
+			}
+			data, err := os.ReadFile(copied.Content[i+1].Value)
+			if err != nil {
+				return nil, err
+			}
+			copied.Content[i] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			copied.Content[i+1] = &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Value: strings.TrimRight(string(data), "\r\n"),
+			}
+		}
+	}
+	return &copied, nil
+}
+
+func expandScalar(node *yaml.Node) (*yaml.Node, error) {
+	copied := *node
+	copied.Anchor = ""
+	if !strings.Contains(node.Value, "${") {
+		return &copied, nil
+	}
+
+	var err error
+	copied.Value = envReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = errors.New("environment variable " + name + " is not set")
+		}
+		return value
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Let plain scalars be interpreted according to their new values.
+	if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		copied.Tag = ""
+	}
+	return &copied, nil
+}
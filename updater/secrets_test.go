@@ -0,0 +1,50 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("DSDDNS_TEST_SUBNAME", "env-subname")
+	defer os.Unsetenv("DSDDNS_TEST_SUBNAME")
+
+	data := []byte(`
+shared: &Shared {
+    service: duck,
+    token_file: ` + path + `
+  }
+records:
+  - << : *Shared
+    type: A
+    subname: ${DSDDNS_TEST_SUBNAME}`)
+	var config struct {
+		Records Updaters
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	s := config.Records[0].Service.(*DuckService)
+	if s.conf.Token != "file-token" {
+		t.Errorf("Token = %q; want file-token", s.conf.Token)
+	}
+	if s.conf.Subname != "env-subname" {
+		t.Errorf("Subname = %q; want env-subname", s.conf.Subname)
+	}
+
+	data = []byte(`
+- service: duck
+  type: A
+  subname: ${DSDDNS_TEST_UNDEFINED}`)
+	var updaters Updaters
+	if err := yaml.Unmarshal(data, &updaters); err == nil {
+		t.Error("Undefined environment variables should be an error")
+	}
+}
@@ -0,0 +1,90 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A StateFile persists the state of each record across restarts, so that
+// records are not resubmitted and retry delays are not forgotten.
+type StateFile struct {
+	path    string
+	records map[string]*recordState
+}
+
+type recordState struct {
+	Record      string    `json:"record"`
+	Submitted   net.IP    `json:"submitted,omitempty"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	LastError   string    `json:"last_error,omitempty"`
+	RetryAfter  time.Time `json:"retry_after"`
+}
+
+// LoadState restores the state of these updaters from the provided file, which
+// need not exist yet, and saves their state to it after every submission.
+// Records whose configuration has changed since the file was written start
+// afresh.
+func (u *Updaters) LoadState(path string) error {
+	saved := make(map[string]*recordState)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	state := &StateFile{path: path, records: make(map[string]*recordState)}
+	for _, updater := range *u {
+		if rs, ok := saved[updater.fingerprint]; ok {
+			updater.submitted = rs.Submitted
+			updater.submittedAt = rs.SubmittedAt
+			updater.lastError = rs.LastError
+			updater.tryAfter = rs.RetryAfter
+			state.records[updater.fingerprint] = rs
+		}
+		updater.state = state
+	}
+	return nil
+}
+
+// save records the state of the provided updater and writes the file.
+func (s *StateFile) save(u *Updater) error {
+	s.records[u.fingerprint] = &recordState{
+		Record:      u.Service.Identifier() + " " + RecordTypeString(u.Type),
+		Submitted:   u.submitted,
+		SubmittedAt: u.submittedAt,
+		LastError:   u.lastError,
+		RetryAfter:  u.tryAfter,
+	}
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file, then rename it over the old one, so that the
+	// file is never left half-written.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
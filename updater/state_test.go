@@ -0,0 +1,77 @@
+package updater
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	data := []byte(`
+- service: duck
+  type: A
+  subname: dsddns
+  token: XXXXXXXX
+- service: duck
+  type: AAAA
+  subname: dsddns
+  token: XXXXXXXX`)
+
+	var before Updaters
+	if err := yaml.Unmarshal(data, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	retryAfter := time.Now().Add(time.Hour).Round(time.Second)
+	before[0].submitted = net.ParseIP("192.0.2.1")
+	before[0].submittedAt = time.Now().Round(time.Second)
+	before[1].lastError = "badauth"
+	before[1].tryAfter = retryAfter
+	for _, u := range before {
+		if err := u.state.save(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var after Updaters
+	if err := yaml.Unmarshal(data, &after); err != nil {
+		t.Fatal(err)
+	}
+	if err := after.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if !after[0].submitted.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("Submitted IP = %s; want 192.0.2.1", after[0].submitted)
+	}
+	if !after[0].submittedAt.Equal(before[0].submittedAt) {
+		t.Errorf("Submission time = %s; want %s", after[0].submittedAt, before[0].submittedAt)
+	}
+	if after[1].lastError != "badauth" {
+		t.Errorf("Last error = %q; want badauth", after[1].lastError)
+	}
+	if !after[1].tryAfter.Equal(retryAfter) {
+		t.Errorf("Retry time = %s; want %s", after[1].tryAfter, retryAfter)
+	}
+
+	changed := []byte(`
+- service: duck
+  type: A
+  subname: dsddns
+  token: YYYYYYYY`)
+	var reconfigured Updaters
+	if err := yaml.Unmarshal(changed, &reconfigured); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconfigured.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if reconfigured[0].submitted != nil {
+		t.Error("State should not be restored for a reconfigured record")
+	}
+}
@@ -0,0 +1,178 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// Session Traversal Utilities for NAT (STUN)
+// see https://www.rfc-editor.org/rfc/rfc5389
+
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112a442
+	stunHeaderLength    = 20
+
+	stunMappedAddress    = 0x0001
+	stunXorMappedAddress = 0x0020
+
+	stunTimeout    = 5 * time.Second
+	stunRetransmit = 500 * time.Millisecond
+)
+
+// stunService asks a STUN server for the address it sees our requests come
+// from.
+type stunService struct {
+	name   string
+	server string
+}
+
+func (s stunService) Name() string {
+	return s.name
+}
+
+func (s stunService) IPv4Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	return stunQuery(ctx, "udp4", s.server, dc)
+}
+
+func (s stunService) IPv6Addr(ctx context.Context, dc dialContext) (net.IP, error) {
+	return stunQuery(ctx, "udp6", s.server, dc)
+}
+
+// stunQuery sends a Binding Request to a STUN server and returns the mapped
+// address from its response. Requests are retransmitted until the context
+// expires, or a short timeout if the context has no deadline.
+func stunQuery(ctx context.Context, network, server string, dc dialContext) (net.IP, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, stunTimeout)
+		defer cancel()
+	}
+	conn, err := dc(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var txid [12]byte
+	if _, err := rand.Read(txid[:]); err != nil {
+		return nil, err
+	}
+	request := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	copy(request[8:], txid[:])
+
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1500)
+	for {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		wait := time.Now().Add(stunRetransmit)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		conn.SetReadDeadline(wait)
+		for {
+			n, err := conn.Read(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if ip, err := parseStunResponse(buf[:n], txid); err == nil {
+				return ip, nil
+			} else if !errors.Is(err, errStunIgnored) {
+				return nil, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, errors.New("STUN request timed out")
+		}
+	}
+}
+
+var errStunIgnored = errors.New("not a response to our request")
+
+// parseStunResponse extracts the mapped address from a Binding Response. It
+// returns errStunIgnored for packets that do not answer the request with the
+// provided transaction ID.
+func parseStunResponse(msg []byte, txid [12]byte) (net.IP, error) {
+	if len(msg) < stunHeaderLength ||
+		binary.BigEndian.Uint32(msg[4:]) != stunMagicCookie ||
+		!bytes.Equal(msg[8:20], txid[:]) {
+		return nil, errStunIgnored
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != stunBindingResponse {
+		return nil, errors.New("STUN server returned an error")
+	}
+	length := int(binary.BigEndian.Uint16(msg[2:]))
+	if stunHeaderLength+length > len(msg) {
+		return nil, errors.New("truncated STUN response")
+	}
+
+	var mapped net.IP
+	attrs := msg[stunHeaderLength : stunHeaderLength+length]
+	for len(attrs) >= 4 {
+		atype := binary.BigEndian.Uint16(attrs[0:])
+		alen := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+alen > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+alen]
+		switch atype {
+		case stunXorMappedAddress:
+			// The address is XORed with the magic cookie and transaction ID.
+			if ip := stunAddress(value); ip != nil {
+				for i := range ip {
+					ip[i] ^= msg[4+i]
+				}
+				return ip.To16(), nil
+			}
+		case stunMappedAddress:
+			mapped = stunAddress(value)
+		}
+		// Attributes are padded to a multiple of four bytes.
+		next := 4 + (alen+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if mapped != nil {
+		return mapped.To16(), nil
+	}
+	return nil, errors.New("STUN response has no mapped address")
+}
+
+// stunAddress decodes the address part of a (XOR-)MAPPED-ADDRESS attribute.
+func stunAddress(value []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	return ip
+}
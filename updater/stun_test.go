@@ -0,0 +1,104 @@
+package updater
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// startStunServer runs a STUN responder that ignores the first request, to
+// exercise retransmission, and answers the rest with the provided attribute.
+func startStunServer(t *testing.T, attr func(msg []byte, from *net.UDPAddr) []byte) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for requests := 0; ; requests++ {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if requests == 0 || n < stunHeaderLength {
+				continue
+			}
+			value := attr(buf[:n], from.(*net.UDPAddr))
+			resp := make([]byte, stunHeaderLength, stunHeaderLength+len(value))
+			binary.BigEndian.PutUint16(resp[0:], stunBindingResponse)
+			binary.BigEndian.PutUint16(resp[2:], uint16(len(value)))
+			copy(resp[4:], buf[4:20])
+			resp = append(resp, value...)
+			conn.WriteTo(resp, from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func stunAttribute(atype uint16, family byte, port uint16, ip []byte) []byte {
+	attr := make([]byte, 8, 8+len(ip))
+	binary.BigEndian.PutUint16(attr[0:], atype)
+	binary.BigEndian.PutUint16(attr[2:], uint16(4+len(ip)))
+	attr[5] = family
+	binary.BigEndian.PutUint16(attr[6:], port)
+	return append(attr, ip...)
+}
+
+func TestStunQuery(t *testing.T) {
+	server := startStunServer(t, func(msg []byte, from *net.UDPAddr) []byte {
+		ip := from.IP.To4()
+		xored := make([]byte, len(ip))
+		for i := range ip {
+			xored[i] = ip[i] ^ msg[4+i]
+		}
+		return stunAttribute(stunXorMappedAddress, 0x01, uint16(from.Port)^0x2112, xored)
+	})
+
+	service := stunService{name: "test", server: server}
+	dial := net.Dialer{}
+	ip, err := service.IPv4Addr(context.Background(), dial.DialContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Address = %s; want 127.0.0.1", ip)
+	}
+}
+
+func TestParseStunResponse(t *testing.T) {
+	txid := [12]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	message := func(attrs ...[]byte) []byte {
+		msg := make([]byte, stunHeaderLength)
+		binary.BigEndian.PutUint16(msg[0:], stunBindingResponse)
+		binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+		copy(msg[8:], txid[:])
+		for _, attr := range attrs {
+			msg = append(msg, attr...)
+		}
+		binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-stunHeaderLength))
+		return msg
+	}
+
+	want := net.ParseIP("2001:db8::1")
+	key := message()[4:20]
+	xored := make([]byte, net.IPv6len)
+	for i := range xored {
+		xored[i] = want[i] ^ key[i]
+	}
+	software := []byte{0x80, 0x22, 0, 5, 'd', 's', 'd', 'n', 's', 0, 0, 0}
+	ip, err := parseStunResponse(message(software, stunAttribute(stunXorMappedAddress, 0x02, 0, xored)), txid)
+	if err != nil || !ip.Equal(want) {
+		t.Errorf("XOR-MAPPED-ADDRESS = %s, %v; want %s", ip, err, want)
+	}
+
+	ip, err = parseStunResponse(message(stunAttribute(stunMappedAddress, 0x01, 0, []byte{192, 0, 2, 1})), txid)
+	if err != nil || !ip.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("MAPPED-ADDRESS = %s, %v; want 192.0.2.1", ip, err)
+	}
+
+	if _, err := parseStunResponse(message(), [12]byte{}); err != errStunIgnored {
+		t.Errorf("Mismatched transaction ID: err = %v; want errStunIgnored", err)
+	}
+}
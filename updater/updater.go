@@ -3,9 +3,13 @@ package updater
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"log"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,44 +39,112 @@ func RecordTypeString(rtype RecordType) string {
 	}
 }
 
+// IPSource represents a method of determining the current IP address.
+type IPSource int
+
+const (
+	// WebSource reads the address from an Internet service.
+	WebSource IPSource = iota
+
+	// InterfaceSource reads the address directly from a network interface.
+	InterfaceSource
+
+	// StunSource reads the address from a STUN server.
+	StunSource
+
+	// DNSSource reads the address from a DNS server.
+	DNSSource
+
+	// RouterSource asks the router for its WAN address.
+	RouterSource
+)
+
 // An Updater manages a single DNS record.
 type Updater struct {
-	Type       RecordType
-	Interface  string
-	Service    RecordService
-	IPOffset   net.IP
-	IPMaskBits int
-	tryAfter   time.Time
-	submitted  net.IP
-	lookup     IPLookup
+	Type        RecordType
+	Interface   string
+	Source      IPSource
+	Service     RecordService
+	IPOffset    net.IP
+	IPMaskBits  int
+	tryAfter    time.Time
+	submitted   net.IP
+	submittedAt time.Time
+	lastError   string
+	lookup      IPLookup
+	checked     bool
+	verifier    *dnsVerifier
+	state       *StateFile
+	fingerprint string
 	yaml.Unmarshaler
 }
 
 // UnmarshalYAML constructs an updater from a YAML configuration.
 func (u *Updater) UnmarshalYAML(value *yaml.Node) error {
 	var aux struct {
-		Service    string
-		Type       string
-		Interface  string
-		IPSLAAC    string `yaml:"ip_slaac"`
-		IPOffset   string `yaml:"ip_offset"`
-		IPMaskBits int    `yaml:"ip_mask_bits"`
+		Service        string
+		Type           string
+		Interface      string
+		IPSource       string `yaml:"ip_source"`
+		IPSLAAC        string `yaml:"ip_slaac"`
+		IPOffset       string `yaml:"ip_offset"`
+		IPMaskBits     int    `yaml:"ip_mask_bits"`
+		Verify         bool
+		VerifyResolver string `yaml:"verify_resolver"`
+	}
+	value, err := resolveSecrets(value)
+	if err != nil {
+		return err
 	}
 	if err := value.Decode(&aux); err != nil {
 		return err
 	}
 
+	// Identify this record by its entire configuration.
+	var raw map[string]interface{}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	enc, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(enc)
+	u.fingerprint = hex.EncodeToString(sum[:8])
+
 	switch strings.ToLower(aux.Service) {
+	case "afraid":
+		u.Service = &AfraidService{}
 	case "cloudflare":
 		u.Service = &CloudflareService{}
+	case "desec":
+		u.Service = &DeSECService{}
+	case "digitalocean":
+		u.Service = &DigitalOceanService{}
 	case "duck":
 		u.Service = &DuckService{}
+	case "gandi":
+		u.Service = &GandiService{}
 	case "genericnoip":
 		u.Service = &NoIPService{}
-	case "noip":
-		u.Service = &NoIPService{DefinedEndpoint: "https://dynupdate.no-ip.com/nic/update"}
 	case "google":
 		u.Service = &NoIPService{DefinedEndpoint: "https://domains.google.com/nic/update"}
+	case "hetzner":
+		u.Service = &HetznerService{}
+	case "linode":
+		u.Service = &LinodeService{}
+	case "namecheap":
+		u.Service = &NamecheapService{}
+	case "noip":
+		u.Service = &NoIPService{DefinedEndpoint: "https://dynupdate.no-ip.com/nic/update"}
+	case "porkbun":
+		u.Service = &PorkbunService{}
+	case "powerdns":
+		u.Service = &PowerDNSService{}
+	case "rfc2136":
+		u.Service = &RFC2136Service{}
+	case "route53":
+		u.Service = &Route53Service{}
 	default:
 		return errors.New("unknown service")
 	}
@@ -95,6 +167,27 @@ func (u *Updater) UnmarshalYAML(value *yaml.Node) error {
 
 	u.Interface = aux.Interface
 
+	switch strings.ToLower(aux.IPSource) {
+	case "", "web":
+		u.Source = WebSource
+	case "interface":
+		if u.Interface == "" {
+			return errors.New("interface IP source requires an interface")
+		}
+		u.Source = InterfaceSource
+	case "stun":
+		u.Source = StunSource
+	case "dns":
+		u.Source = DNSSource
+	case "router":
+		if u.Type != ARecord {
+			return errors.New("router IP source only supports A records")
+		}
+		u.Source = RouterSource
+	default:
+		return errors.New("unknown IP source")
+	}
+
 	if ip := net.ParseIP(aux.IPOffset); ip != nil {
 		u.IPOffset = ip
 		u.IPMaskBits = aux.IPMaskBits
@@ -106,35 +199,118 @@ func (u *Updater) UnmarshalYAML(value *yaml.Node) error {
 		}
 	}
 
+	if aux.Verify || aux.VerifyResolver != "" {
+		if !isDomainName(u.Service.Identifier()) {
+			return errors.New("verify requires the record's full domain name")
+		}
+		u.verifier = newDNSVerifier(aux.VerifyResolver)
+	}
+
 	return nil
 }
 
 // Update attempts to refresh the record if necessary. It should be called every
 // few minutes.
 func (u *Updater) Update(ctx context.Context, logger *log.Logger) {
-	rawip := u.lookup.WebFacingIP(ctx, u.Type, u.Interface)
+	defer metrics.observeRecord(u)
+	if !u.checked {
+		u.checked = true
+		u.checkRecord(ctx, logger)
+	}
+
+	rawip := u.currentIP(ctx, logger)
 	if rawip == nil {
 		return
 	}
 
 	ip := AddIP(MaskIP(rawip, u.IPMaskBits), u.IPOffset)
-	if !ip.Equal(u.submitted) && time.Now().After(u.tryAfter) {
-		id := u.Service.Identifier()
-		logger.Println(id, RecordTypeString(u.Type), "➤", ip.String())
-
-		if retryAfter, err := u.Service.Submit(ctx, u.Type, ip); err != nil {
-			logger.Println(id, "✗", err)
-			logger.Println(id, "next attempt in", retryAfter.String())
-			u.tryAfter = time.Now().Add(retryAfter)
-		} else {
+	if !time.Now().After(u.tryAfter) {
+		return
+	}
+	if u.verifier != nil {
+		u.verify(ctx, logger, ip)
+	}
+	if ip.Equal(u.submitted) {
+		return
+	}
+
+	id := u.Service.Identifier()
+	logger.Println(id, RecordTypeString(u.Type), "➤", ip.String())
+
+	retryAfter, err := u.Service.Submit(ctx, u.Type, ip)
+	if err != nil {
+		logger.Println(id, "✗", err)
+		logger.Println(id, "next attempt in", retryAfter.String())
+		u.tryAfter = time.Now().Add(retryAfter)
+		u.lastError = err.Error()
+	} else {
+		u.submitted = ip
+		u.submittedAt = time.Now()
+		u.lastError = ""
+	}
+	metrics.observeSubmission(u, err)
+	u.saveState(logger)
+}
+
+// verify compares the published value of the record against the provided
+// address. If the record is already up to date, there is no need to submit it;
+// if someone else has changed it, it needs to be submitted again.
+func (u *Updater) verify(ctx context.Context, logger *log.Logger, ip net.IP) {
+	if ip.Equal(u.submitted) && time.Since(u.submittedAt) < verifyGrace {
+		return
+	}
+
+	id := u.Service.Identifier()
+	published, err := u.verifier.Published(ctx, id, u.Type)
+	if err != nil {
+		logger.Println(id, "failed to verify published address:", err)
+		return
+	}
+	if containsIP(published, ip) {
+		if !ip.Equal(u.submitted) {
+			logger.Println(id, RecordTypeString(u.Type), "already published as", ip.String())
 			u.submitted = ip
+			u.saveState(logger)
 		}
+	} else if ip.Equal(u.submitted) {
+		logger.Println(id, RecordTypeString(u.Type), "no longer published as", ip.String())
+		u.submitted = nil
+		u.saveState(logger)
+	}
+}
+
+// A recordFinder is a service that looks up the identifiers of its record.
+type recordFinder interface {
+	findRecord(ctx context.Context, rtype RecordType) error
+}
+
+// checkRecord looks up the record's identifiers on the first run, so that a
+// wrong zone or credentials are reported right away instead of at the first
+// address change. A missing record is reported when it is submitted.
+func (u *Updater) checkRecord(ctx context.Context, logger *log.Logger) {
+	finder, ok := u.Service.(recordFinder)
+	if !ok {
+		return
+	}
+	err := finder.findRecord(ctx, u.Type)
+	var missing missingRecordError
+	if err != nil && !errors.As(err, &missing) {
+		logger.Println(u.Service.Identifier(), "failed to look up record:", err)
+	}
+}
+
+func (u *Updater) saveState(logger *log.Logger) {
+	if u.state == nil {
+		return
+	}
+	if err := u.state.save(u); err != nil {
+		logger.Println(u.Service.Identifier(), "failed to save state:", err)
 	}
 }
 
 // DryRun performs an IP address lookup, but does not refresh the record.
 func (u *Updater) DryRun(ctx context.Context, logger *log.Logger) {
-	rawip := u.lookup.WebFacingIP(ctx, u.Type, u.Interface)
+	rawip := u.currentIP(ctx, logger)
 	if rawip == nil {
 		log.Println("failed to look up IP address")
 		return
@@ -144,6 +320,21 @@ func (u *Updater) DryRun(ctx context.Context, logger *log.Logger) {
 	logger.Println(u.Service.Identifier(), RecordTypeString(u.Type), "➤", ip.String())
 }
 
+func (u *Updater) currentIP(ctx context.Context, logger *log.Logger) net.IP {
+	switch u.Source {
+	case InterfaceSource:
+		return u.lookup.InterfaceIP(u.Type, u.Interface)
+	case StunSource:
+		return u.lookup.StunIP(ctx, u.Type, u.Interface)
+	case DNSSource:
+		return u.lookup.DNSIP(ctx, u.Type, u.Interface)
+	case RouterSource:
+		return u.lookup.RouterIP(ctx, u.Interface)
+	default:
+		return u.lookup.WebFacingIP(ctx, logger, u.Type, u.Interface)
+	}
+}
+
 // SlaacBits returns an IPv6 address with the lower 64 bits derived from the
 // provided MAC address using the EUI-64 derivation.
 func SlaacBits(mac net.HardwareAddr) net.IP {
@@ -186,6 +377,50 @@ func fromRight(ip net.IP, place int) byte {
 	return ip[len(ip)-place-1]
 }
 
+// missingRecordError reports that the record to update does not exist. Services
+// that can create records do so if create_if_missing is set.
+type missingRecordError struct {
+	name  string
+	rtype RecordType
+}
+
+func (e missingRecordError) Error() string {
+	return "no " + RecordTypeString(e.rtype) + " record exists for " + e.name + " (set create_if_missing to create it)"
+}
+
+// missingZoneError reports that no zone managed by the service contains the
+// record.
+type missingZoneError struct {
+	name string
+}
+
+func (e missingZoneError) Error() string {
+	return "cannot find a zone for " + e.name
+}
+
+// zoneCandidates lists the domains that could be the zone containing the
+// provided name, from the longest to the shortest. Top-level domains are
+// excluded.
+func zoneCandidates(name string) []string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	candidates := make([]string, 0)
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	return candidates
+}
+
+// relativeName returns the provided name relative to its zone, or "@" if it is
+// the zone apex.
+func relativeName(name, zone string) string {
+	name = strings.TrimSuffix(name, ".")
+	zone = strings.TrimSuffix(zone, ".")
+	if strings.EqualFold(name, zone) {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
 // Updaters represents a slice of updaters defined by a YAML configuration. All
 // updaters share the same IP address lookup cache.
 type Updaters []*Updater
@@ -197,24 +432,103 @@ func (u *Updaters) UnmarshalYAML(value *yaml.Node) error {
 	}
 
 	lookup := NewIPLookup()
+	seen := make(map[string]int)
 	for _, node := range value.Content {
 		var updater Updater
 		if err := node.Decode(&updater); err != nil {
 			return err
 		}
+		// Records are told apart by their configuration. Number any repeated
+		// ones, so that they still have state of their own.
+		seen[updater.fingerprint]++
+		if n := seen[updater.fingerprint]; n > 1 {
+			updater.fingerprint += "-" + strconv.Itoa(n)
+		}
 		updater.lookup = lookup
 		*u = append(*u, &updater)
 	}
+	linkRRSets(*u)
 	return nil
 }
 
-// Update processes all of the updaters in this slice.
+// UseIPServices replaces the built-in services that look up web-facing IP
+// addresses.
+func (u *Updaters) UseIPServices(services *IPServices) {
+	for _, updater := range *u {
+		updater.lookup.web = services
+	}
+}
+
+// Inherit carries over the in-memory state of records from a previous
+// configuration, so that reloading the configuration does not resubmit them.
+// Only records whose configuration is unchanged keep their state. The IP
+// address lookup cache is carried over, too, unless the IP address services
+// have changed.
+func (u *Updaters) Inherit(old Updaters) {
+	prev := make(map[string]*Updater)
+	for _, updater := range old {
+		prev[updater.fingerprint] = updater
+	}
+	for _, updater := range *u {
+		if len(old) > 0 && updater.lookup.web.equal(old[0].lookup.web) {
+			updater.lookup = old[0].lookup
+		}
+		if o, ok := prev[updater.fingerprint]; ok {
+			updater.Service = o.Service
+			updater.tryAfter = o.tryAfter
+			updater.submitted = o.submitted
+			updater.submittedAt = o.submittedAt
+			updater.lastError = o.lastError
+		}
+	}
+	linkRRSets(*u)
+}
+
+// Update processes all of the updaters in this slice. The IP addresses are
+// looked up again on every call; records that share an address source share
+// one lookup.
 func (u *Updaters) Update(ctx context.Context, logger *log.Logger) {
+	for _, updater := range *u {
+		updater.lookup.expire()
+	}
 	for _, updater := range *u {
 		updater.Update(ctx, logger)
 	}
 }
 
+// UpdateInterface processes the updaters bound to the provided network
+// interface, bypassing the IP address lookup cache. It should be called when
+// the interface's addresses change.
+func (u *Updaters) UpdateInterface(ctx context.Context, logger *log.Logger, intname string) {
+	for _, updater := range *u {
+		if updater.Interface == intname {
+			updater.lookup.Invalidate(updater.Type, intname)
+			updater.Update(ctx, logger)
+		}
+	}
+}
+
+// UsesRouter determines whether any of the updaters reads the address from the
+// router.
+func (u *Updaters) UsesRouter() bool {
+	for _, updater := range *u {
+		if updater.Source == RouterSource {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateRouter processes the updaters that read the address from the router.
+// It should be called when the router announces an address change.
+func (u *Updaters) UpdateRouter(ctx context.Context, logger *log.Logger) {
+	for _, updater := range *u {
+		if updater.Source == RouterSource {
+			updater.Update(ctx, logger)
+		}
+	}
+}
+
 // DryRun tests all of the updaters in this slice.
 func (u *Updaters) DryRun(ctx context.Context, logger *log.Logger) {
 	logger.Println("(Dry run; no changes will be made.)")
@@ -229,7 +543,8 @@ type RecordService interface {
 	// Submit a new record value.
 	Submit(context.Context, RecordType, net.IP) (retryAfter time.Duration, err error)
 
-	// Retrieve a human-readable name for this record.
+	// Retrieve a human-readable name for this record. Verified records are
+	// looked up in DNS by this name, so it must be their full domain name.
 	Identifier() string
 
 	// Determine support for a record type.
@@ -1,12 +1,46 @@
 package updater
 
 import (
+	"context"
+	"io"
+	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// stubService records the addresses submitted to it, calling onSubmit first if
+// it is set.
+type stubService struct {
+	name      string
+	submitted []net.IP
+	onSubmit  func()
+}
+
+func (s *stubService) Submit(ctx context.Context, rtype RecordType, ip net.IP) (time.Duration, error) {
+	if s.onSubmit != nil {
+		s.onSubmit()
+	}
+	s.submitted = append(s.submitted, ip)
+	return 0, nil
+}
+
+func (s *stubService) Identifier() string {
+	return s.name
+}
+
+func (s *stubService) SupportsRecord(rtype RecordType) bool {
+	return true
+}
+
+func (s *stubService) UnmarshalYAML(value *yaml.Node) error {
+	return nil
+}
+
 func TestSlaacBits(t *testing.T) {
 	got := SlaacBits([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
 	slaac := net.IP([]byte{
@@ -61,6 +95,7 @@ func TestAddIP(t *testing.T) {
 func TestUnmarshalUpdaters(t *testing.T) {
 	data := []byte(`
 - service: cloudflare
+  api_token: token
   type: AAAA
   interface: eth0
   ip_offset: ::1
@@ -83,3 +118,71 @@ func TestUnmarshalUpdaters(t *testing.T) {
 		t.Errorf("Offset IP = %s; want ::1", got[0].IPOffset.String())
 	}
 }
+
+func TestZoneCandidates(t *testing.T) {
+	got := zoneCandidates("host.sub.example.com.")
+	want := []string{"host.sub.example.com", "sub.example.com", "example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("Zone candidates = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Zone candidates = %v; want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestUpdatersLookupEveryPass(t *testing.T) {
+	var queries int
+	addrs := []string{"192.0.2.1", "192.0.2.2"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, addrs[queries%len(addrs)])
+		queries++
+	}))
+	defer server.Close()
+
+	var s IPServices
+	if err := yaml.Unmarshal([]byte("services: [{ipv4_url: "+server.URL+"}]"), &s); err != nil {
+		t.Fatal(err)
+	}
+	lookup := NewIPLookup()
+	lookup.web = &s
+	first := &stubService{name: "first.example.com"}
+	second := &stubService{name: "second.example.com"}
+	updaters := Updaters{
+		&Updater{Type: ARecord, Service: first, lookup: lookup},
+		&Updater{Type: ARecord, Service: second, lookup: lookup},
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	updaters.Update(context.Background(), logger)
+	updaters.Update(context.Background(), logger)
+	if queries != 2 {
+		t.Errorf("Queries = %d; want one per pass", queries)
+	}
+	for _, service := range []*stubService{first, second} {
+		if len(service.submitted) != 2 || !service.submitted[1].Equal(net.ParseIP(addrs[1])) {
+			t.Errorf("%s submitted %v; want %v", service.name, service.submitted, addrs)
+		}
+	}
+}
+
+func TestUnmarshalDuplicateUpdaters(t *testing.T) {
+	data := []byte(`
+- service: duck
+  type: A
+  subname: dsddns
+  token: XXXXXXXX
+- service: duck
+  type: A
+  subname: dsddns
+  token: XXXXXXXX`)
+	var got Updaters
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].fingerprint == got[1].fingerprint {
+		t.Errorf("Identical records share the fingerprint %s", got[0].fingerprint)
+	}
+}
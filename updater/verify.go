@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Skip verification for a while after a submission, because it can take some
+// time for every authoritative server to see the new value.
+const verifyGrace = 10 * time.Minute
+
+// A dnsVerifier looks up the value of a record as it is currently published.
+type dnsVerifier struct {
+	// The recursive resolver to query. If it is empty, the record's
+	// authoritative nameservers are queried instead.
+	resolver string
+}
+
+func newDNSVerifier(resolver string) *dnsVerifier {
+	if resolver == "" {
+		return &dnsVerifier{}
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+	return &dnsVerifier{resolver: resolver}
+}
+
+// Published returns the addresses currently published for the provided name.
+func (v *dnsVerifier) Published(ctx context.Context, name string, rtype RecordType) ([]net.IP, error) {
+	var qtype uint16
+	switch rtype {
+	case ARecord:
+		qtype = dns.TypeA
+	case AAAARecord:
+		qtype = dns.TypeAAAA
+	default:
+		return nil, errors.New("unsupported record type")
+	}
+
+	var servers []string
+	if v.resolver != "" {
+		servers = []string{v.resolver}
+	} else {
+		var err error
+		servers, err = authoritativeServers(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = v.resolver != ""
+	client := &dns.Client{}
+	err := errors.New("no nameservers")
+	for _, server := range servers {
+		var resp *dns.Msg
+		resp, _, err = client.ExchangeContext(ctx, msg, server)
+		if err != nil {
+			continue
+		}
+		switch resp.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+		default:
+			err = errors.New(server + " responded with " + dns.RcodeToString[resp.Rcode])
+			continue
+		}
+		ips := make([]net.IP, 0)
+		for _, rr := range resp.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				ips = append(ips, v.A)
+			case *dns.AAAA:
+				ips = append(ips, v.AAAA)
+			}
+		}
+		return ips, nil
+	}
+	return nil, err
+}
+
+// authoritativeServers finds the nameservers for the zone containing the
+// provided name.
+func authoritativeServers(ctx context.Context, name string) ([]string, error) {
+	zone := strings.TrimSuffix(name, ".")
+	for zone != "" {
+		nss, err := net.DefaultResolver.LookupNS(ctx, zone)
+		if err == nil && len(nss) > 0 {
+			servers := make([]string, 0, len(nss))
+			for _, ns := range nss {
+				servers = append(servers, net.JoinHostPort(strings.TrimSuffix(ns.Host, "."), "53"))
+			}
+			return servers, nil
+		}
+		if i := strings.IndexByte(zone, '.'); i >= 0 {
+			zone = zone[i+1:]
+		} else {
+			zone = ""
+		}
+	}
+	return nil, errors.New("cannot find the nameservers for " + name)
+}
+
+// isDomainName determines whether a name is a domain name with at least two
+// labels, such as host.example.com.
+func isDomainName(name string) bool {
+	_, ok := dns.IsDomainName(name)
+	return ok && dns.CountLabel(name) > 1
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, other := range ips {
+		if other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
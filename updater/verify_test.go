@@ -0,0 +1,153 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDNSVerifierPublished(t *testing.T) {
+	addr := startDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if req.Question[0].Name == "host.example.com." && req.Question[0].Qtype == dns.TypeAAAA {
+			rr, _ := dns.NewRR("host.example.com. 300 IN AAAA 2001:db8::1")
+			resp.Answer = append(resp.Answer, rr)
+		} else {
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+
+	v := newDNSVerifier(addr)
+	got, err := v.Published(context.Background(), "host.example.com", AAAARecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsIP(got, net.ParseIP("2001:db8::1")) {
+		t.Errorf("Published addresses = %v; want 2001:db8::1", got)
+	}
+
+	got, err = v.Published(context.Background(), "missing.example.com", AAAARecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Published addresses = %v; want none", got)
+	}
+}
+
+func TestUpdaterVerify(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		published = "192.0.2.1"
+		queries   int
+	)
+	addr := startDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries++
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		rr, _ := dns.NewRR("host.example.com. 300 IN A " + published)
+		resp.Answer = append(resp.Answer, rr)
+		w.WriteMsg(resp)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "192.0.2.1")
+	}))
+	defer server.Close()
+
+	var s IPServices
+	if err := yaml.Unmarshal([]byte("services: [{ipv4_url: "+server.URL+"}]"), &s); err != nil {
+		t.Fatal(err)
+	}
+	service := &stubService{name: "host.example.com"}
+	path := filepath.Join(t.TempDir(), "state.json")
+	u := &Updater{
+		Type:     ARecord,
+		Service:  service,
+		lookup:   NewIPLookup(),
+		verifier: newDNSVerifier(addr),
+		state:    &StateFile{path: path, records: make(map[string]*recordState)},
+	}
+	u.lookup.web = &s
+	logger := log.New(io.Discard, "", 0)
+
+	// The record is already published, so it is not submitted.
+	u.Update(context.Background(), logger)
+	if len(service.submitted) != 0 {
+		t.Errorf("Submitted %v; want nothing", service.submitted)
+	}
+	if !u.submitted.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("Submitted IP = %s; want 192.0.2.1", u.submitted)
+	}
+	if rs := u.state.records[u.fingerprint]; rs == nil || !rs.Submitted.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("Saved state = %+v; want 192.0.2.1", rs)
+	}
+
+	// Recent submissions are not verified.
+	mu.Lock()
+	published = "198.51.100.1"
+	queries = 0
+	mu.Unlock()
+	u.submittedAt = time.Now()
+	u.lookup.expire()
+	u.Update(context.Background(), logger)
+	mu.Lock()
+	if queries != 0 {
+		t.Errorf("Queries = %d; want none during the grace period", queries)
+	}
+	mu.Unlock()
+	if len(service.submitted) != 0 {
+		t.Errorf("Submitted %v; want nothing", service.submitted)
+	}
+
+	// Once the grace period is over, the changed record is submitted again.
+	// The change is saved before submitting, in case the submission never
+	// finishes.
+	service.onSubmit = func() {
+		if rs := u.state.records[u.fingerprint]; rs == nil || rs.Submitted != nil {
+			t.Errorf("Saved state before submitting = %+v; want no address", rs)
+		}
+	}
+	u.submittedAt = time.Now().Add(-verifyGrace - time.Minute)
+	u.lookup.expire()
+	u.Update(context.Background(), logger)
+	if len(service.submitted) != 1 || !service.submitted[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("Submitted %v; want 192.0.2.1", service.submitted)
+	}
+	if time.Since(u.submittedAt) > time.Minute {
+		t.Errorf("Submission time = %s; want now", u.submittedAt)
+	}
+}
+
+func TestVerifyRequiresDomainName(t *testing.T) {
+	for _, test := range []struct {
+		hostname string
+		valid    bool
+	}{
+		{"host.example.com", true},
+		{"host", false},
+		{"", false},
+	} {
+		data := []byte("service: noip\ntype: A\nverify: true\nhostname: \"" + test.hostname + "\"")
+		var u Updater
+		err := yaml.Unmarshal(data, &u)
+		if test.valid && err != nil {
+			t.Errorf("%q: %v", test.hostname, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%q: want an error", test.hostname)
+		}
+	}
+}